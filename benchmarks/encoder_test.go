@@ -46,6 +46,7 @@ func encodeVector(e ltvgo.LtvEncoder) {
 func BenchmarkEncoderSmall(b *testing.B) {
 	e := ltv.NewEncoder()
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		e.Reset()
 		encodeSmall(e)
@@ -56,6 +57,7 @@ func BenchmarkStreamEncoderSmall(b *testing.B) {
 	var buf bytes.Buffer
 	e := ltv.NewStreamEncoder(&buf)
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
 		e.Reset()
@@ -66,6 +68,7 @@ func BenchmarkStreamEncoderSmall(b *testing.B) {
 func BenchmarkEncoderMedium(b *testing.B) {
 	e := ltv.NewEncoder()
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		e.Reset()
 		encodeMedium(e)
@@ -76,6 +79,7 @@ func BenchmarkStreamEncoderMedium(b *testing.B) {
 	var buf bytes.Buffer
 	e := ltv.NewStreamEncoder(&buf)
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
 		e.Reset()
@@ -86,6 +90,7 @@ func BenchmarkStreamEncoderMedium(b *testing.B) {
 func BenchmarkEncoderVector(b *testing.B) {
 	e := ltv.NewEncoder()
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		e.Reset()
 		encodeVector(e)
@@ -96,6 +101,7 @@ func BenchmarkStreamEncoderVector(b *testing.B) {
 	var buf bytes.Buffer
 	e := ltv.NewStreamEncoder(&buf)
 
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		buf.Reset()
 		e.Reset()
@@ -73,6 +73,7 @@ func createLargeData() (l LargeObject) {
 }
 
 func benchMarshalLtv(value any, b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		_, err := ltv.Marshal(value)
 		if err != nil {
@@ -88,6 +89,7 @@ func benchUnmarshalLtv[T TestObject](value T, b *testing.B) {
 	}
 
 	var ret T
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		err := ltv.Unmarshal(data, &ret)
@@ -101,6 +103,65 @@ func BenchmarkLtvMarshalSmall(b *testing.B)  { benchMarshalLtv(smallData, b) }
 func BenchmarkLtvMarshalMedium(b *testing.B) { benchMarshalLtv(medData, b) }
 func BenchmarkLtvMarshalLarge(b *testing.B)  { benchMarshalLtv(largeData, b) }
 
+// Reusing buffers across messages
+
+func benchMarshalAppendLtv(value any, b *testing.B) {
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = ltv.MarshalAppend(buf[:0], value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchMarshalToLtv(value any, b *testing.B) {
+	e := ltv.NewEncoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e.Reset()
+		err := ltv.MarshalTo(e, value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchDecoderReset[T TestObject](value T, b *testing.B) {
+	data, err := ltv.Marshal(value)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	d := ltv.NewDecoder(nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Reset(data)
+		desc, err := d.Next()
+		if err == nil {
+			err = d.Skip(desc)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLtvMarshalAppendSmall(b *testing.B)  { benchMarshalAppendLtv(smallData, b) }
+func BenchmarkLtvMarshalAppendMedium(b *testing.B) { benchMarshalAppendLtv(medData, b) }
+func BenchmarkLtvMarshalAppendLarge(b *testing.B)  { benchMarshalAppendLtv(largeData, b) }
+
+func BenchmarkLtvMarshalToSmall(b *testing.B)  { benchMarshalToLtv(smallData, b) }
+func BenchmarkLtvMarshalToMedium(b *testing.B) { benchMarshalToLtv(medData, b) }
+func BenchmarkLtvMarshalToLarge(b *testing.B)  { benchMarshalToLtv(largeData, b) }
+
+func BenchmarkLtvDecoderResetSmall(b *testing.B)  { benchDecoderReset(smallData, b) }
+func BenchmarkLtvDecoderResetMedium(b *testing.B) { benchDecoderReset(medData, b) }
+func BenchmarkLtvDecoderResetLarge(b *testing.B)  { benchDecoderReset(largeData, b) }
+
 func BenchmarkLtvUnmarshalSmall(b *testing.B)  { benchUnmarshalLtv(smallData, b) }
 func BenchmarkLtvUnmarshalMedium(b *testing.B) { benchUnmarshalLtv(medData, b) }
 func BenchmarkLtvUnmarshalLarge(b *testing.B)  { benchUnmarshalLtv(largeData, b) }
@@ -0,0 +1,68 @@
+// Utility that converts a NumPy .npy or .npz file to it's LiteVector representation.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ThadThompson/ltvgo/npy"
+)
+
+func main() {
+	hexEncoded := flag.Bool("x", false, "hex encoded output")
+	inputFile := flag.String("i", "", "read input from file")
+	outputFile := flag.String("o", "", "write output to file")
+	flag.Parse()
+
+	var r io.Reader
+	var w io.Writer
+
+	if len(*inputFile) > 0 {
+		// Read from file
+		fin, err := os.Open(*inputFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to open input file: ", err)
+			os.Exit(1)
+		}
+		r = fin
+	} else {
+		// Read from stdin
+		r = os.Stdin
+	}
+
+	if len(*outputFile) > 0 {
+		// Write to file
+		fout, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to create output file: ", err)
+			os.Exit(1)
+		}
+		w = fout
+	} else {
+		// Write to standard out
+		w = os.Stdout
+	}
+
+	if *hexEncoded {
+		w = hex.NewEncoder(w)
+	}
+
+	// .npz archives need random access, so the input is read in full
+	buf, err := io.ReadAll(r)
+	if err == nil {
+		if bytes.HasPrefix(buf, []byte("PK\x03\x04")) {
+			err = npy.Npz2Ltv(bytes.NewReader(buf), int64(len(buf)), w)
+		} else {
+			err = npy.Npy2Ltv(bytes.NewReader(buf), w)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
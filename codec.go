@@ -0,0 +1,234 @@
+package ltvgo
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// A codec encodes and decodes a single Go type.
+type codec struct {
+	encode func(LtvEncoder, reflect.Value) error
+	decode func(*Decoder, LtvDesc) (reflect.Value, error)
+}
+
+func newCodec[T any](encode func(LtvEncoder, T) error, decode func(*Decoder, LtvDesc) (T, error)) *codec {
+	return &codec{
+		encode: func(l LtvEncoder, v reflect.Value) error {
+			return encode(l, v.Interface().(T))
+		},
+		decode: func(d *Decoder, desc LtvDesc) (reflect.Value, error) {
+			v, err := decode(d, desc)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(&v).Elem(), nil
+		},
+	}
+}
+
+var (
+	codecRegistry sync.Map // map[reflect.Type]*codec
+	codecCount    atomic.Int32
+)
+
+// RegisterCodec registers functions to encode and decode values of type T,
+// for types that cannot implement Marshaler and Unmarshaler themselves.
+//
+// The encode function must write exactly one value. The decode function is
+// given the descriptor of the next value and must consume it completely,
+// for example with Decoder.ReadValue or Decoder.Skip. Nil values are
+// decoded as the zero value of T without calling decode.
+//
+// Registered codecs take precedence over Marshaler, TextMarshaler and the
+// default encoding of T. Use a Codecs set in MarshalOptions or
+// UnmarshalOptions to override them for a single call.
+func RegisterCodec[T any](encode func(LtvEncoder, T) error, decode func(*Decoder, LtvDesc) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	c := newCodec(encode, decode)
+	if _, loaded := codecRegistry.LoadOrStore(t, c); loaded {
+		codecRegistry.Store(t, c)
+	} else {
+		codecCount.Add(1)
+	}
+
+	// Encoders for types containing T may already be cached.
+	encoderCache.Range(func(k, _ any) bool {
+		encoderCache.Delete(k)
+		return true
+	})
+	fieldCache.Range(func(k, _ any) bool {
+		fieldCache.Delete(k)
+		return true
+	})
+}
+
+// The globally registered codec for a type, if any.
+func registeredCodec(t reflect.Type) *codec {
+	if codecCount.Load() == 0 {
+		return nil
+	}
+	if c, ok := codecRegistry.Load(t); ok {
+		return c.(*codec)
+	}
+	return nil
+}
+
+// A Codecs set holds codecs used for a single Marshal or Unmarshal call,
+// taking precedence over the codecs registered with RegisterCodec.
+// A Codecs set must not be modified while it is in use.
+type Codecs struct {
+	m map[reflect.Type]*codec
+}
+
+// AddCodec adds functions to encode and decode values of type T to a codec set.
+// See RegisterCodec for the requirements on the functions.
+func AddCodec[T any](c *Codecs, encode func(LtvEncoder, T) error, decode func(*Decoder, LtvDesc) (T, error)) {
+	if c.m == nil {
+		c.m = make(map[reflect.Type]*codec)
+	}
+	c.m[reflect.TypeOf((*T)(nil)).Elem()] = newCodec(encode, decode)
+}
+
+func (c *Codecs) lookup(t reflect.Type) *codec {
+	if c == nil {
+		return nil
+	}
+	return c.m[t]
+}
+
+// Encode a value with a codec.
+func (e *encodeState) codec(c *codec, v reflect.Value) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
+	if err := c.encode(e.out(), v); err != nil {
+		e.error(&MarshalerError{v.Type(), err, "codec"})
+	}
+}
+
+func newCodecEncoder(c *codec) encoderFunc {
+	return func(e *encodeState, v reflect.Value, _ encOpts) {
+		e.codec(c, v)
+	}
+}
+
+// Find the per-call codec for a type, if any.
+func (opts encOpts) lookupCodec(t reflect.Type) *codec {
+	if c := opts.codecs.lookup(t); c != nil {
+		return c
+	}
+	if opts.wellKnown {
+		return wellKnownCodecs.lookup(t)
+	}
+	return nil
+}
+
+// Wrap an encoder to check the per-call codecs first.
+func withCodecOverride(t reflect.Type, f encoderFunc) encoderFunc {
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		if opts.codecs == nil && !opts.wellKnown {
+			f(e, v, opts)
+			return
+		}
+
+		if c := opts.lookupCodec(t); c != nil {
+			e.codec(c, v)
+			return
+		}
+
+		// Pointers may bypass the element encoder, e.g. for a TextMarshaler
+		if t.Kind() == reflect.Pointer {
+			if c := opts.lookupCodec(t.Elem()); c != nil {
+				if v.IsNil() {
+					e.out().WriteNil()
+				} else {
+					e.codec(c, v.Elem())
+				}
+				return
+			}
+		}
+
+		// Values may use the codec for their pointer type, e.g. big.Int
+		if t.Kind() != reflect.Pointer {
+			if c := opts.lookupCodec(reflect.PointerTo(t)); c != nil {
+				if !v.CanAddr() {
+					c := reflect.New(t).Elem()
+					c.Set(v)
+					v = c
+				}
+				e.codec(c, v.Addr())
+				return
+			}
+		}
+
+		f(e, v, opts)
+	}
+}
+
+// Find the codec for a decode target type, if any.
+func (d *decodeState) lookupCodec(t reflect.Type) *codec {
+	if c := d.codecs.lookup(t); c != nil {
+		return c
+	}
+	if d.wellKnown {
+		if c := wellKnownCodecs.lookup(t); c != nil {
+			return c
+		}
+	}
+	return registeredCodec(t)
+}
+
+// Decode a value with a codec for the target type (or the type it points
+// to, or a pointer to it).
+// Returns false if there is no codec for the target.
+func (d *decodeState) codecValue(desc LtvDesc, v reflect.Value) (bool, error) {
+	if d.codecs == nil && !d.wellKnown && codecCount.Load() == 0 {
+		return false, nil
+	}
+
+	// Nil is stored as the zero value, as for any other type
+	if desc.TypeCode == Nil {
+		return false, nil
+	}
+
+	t := v.Type()
+	c := d.lookupCodec(t)
+	ptr, elem := false, false
+
+	if c == nil && t.Kind() == reflect.Pointer {
+		c = d.lookupCodec(t.Elem())
+		ptr = true
+	}
+	if c == nil && t.Kind() != reflect.Pointer {
+		c = d.lookupCodec(reflect.PointerTo(t))
+		elem = true
+	}
+
+	if c == nil {
+		return false, nil
+	}
+
+	value, err := c.decode(&d.decoder, desc)
+	if err != nil {
+		return true, err
+	}
+
+	if ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		v = v.Elem()
+	}
+	if elem {
+		// The decoded value is new, so nothing else refers to it
+		if value.IsNil() {
+			return true, nil
+		}
+		value = value.Elem()
+	}
+
+	v.Set(value)
+	return true, nil
+}
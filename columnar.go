@@ -0,0 +1,301 @@
+package ltvgo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Columnar encoding of slices of structs.
+//
+// A struct field tagged with the "columnar" option, for example:
+//
+//	Readings []Reading `ltv:"readings,columnar"`
+//
+// is encoded as a single struct of columns rather than a list of structs.
+// Each column holds one field of every element, so numeric and bool fields
+// become typed vectors:
+//
+//	{"T": I64 vector, "X": F32 vector, "Y": F32 vector}
+//
+// Unmarshal reassembles the slice from the columns, and still accepts the
+// regular list of structs form. Pointers to slices may be columnar too.
+// Structs without any encoded fields are written as a list as usual, as
+// there would be no column to carry the length.
+//
+// The "required" and "default" options of the element fields apply to each
+// element when decoding. The options which change how a single field value
+// is written, "omitempty", "omitzero", "string" and "fixed", have no meaning
+// within a column, and Marshal returns a ColumnarOptionError for them rather
+// than encoding the struct differently from its list form. So does a
+// "remain" field, which could not be preserved per element. A "columnar"
+// field of the element struct is written in the list form inside its column.
+
+// A RaggedColumnsError is returned by Unmarshal when the columns
+// of a columnar field do not all have the same length.
+type RaggedColumnsError struct {
+	Type   reflect.Type // Slice type being decoded
+	Column string       // Name of the mismatched column
+	Len    int          // Length of the mismatched column
+	Want   int          // Length of the preceding columns
+}
+
+func (e *RaggedColumnsError) Error() string {
+	return fmt.Sprintf("ltv: ragged columns for %s: column %q has %d elements, expected %d",
+		e.Type.String(), e.Column, e.Len, e.Want)
+}
+
+// A ColumnarOptionError is returned by Marshal when the element struct of
+// a columnar field has a field with a tag option that can't apply to a column.
+type ColumnarOptionError struct {
+	Type   reflect.Type // Slice type being encoded
+	Field  string       // Name of the element field
+	Option string       // The unsupported tag option
+}
+
+func (e *ColumnarOptionError) Error() string {
+	return fmt.Sprintf("ltv: %q option of field %s cannot be encoded in columnar form in %s",
+		e.Option, e.Field, e.Type.String())
+}
+
+// Find a tag option of the element fields which can't be encoded in
+// columnar form, returning the field name and option.
+func columnarOption(fields *structFields) (string, string) {
+	if fields.remain != nil {
+		return fields.remain.name, "remain"
+	}
+	for i := range fields.list {
+		f := &fields.list[i]
+		switch {
+		case f.omitEmpty:
+			return f.name, "omitempty"
+		case f.omitZero:
+			return f.name, "omitzero"
+		case f.quoted:
+			return f.name, "string"
+		case f.fixed:
+			return f.name, "fixed"
+		}
+	}
+	return "", ""
+}
+
+// Check whether a field type can be encoded in columnar form:
+// a slice of structs, or a pointer to one.
+func isColumnarType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct
+}
+
+// The column slice type for a struct field type.
+// Platform sized integers are widened so the column is written as a vector.
+func columnType(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Int:
+		return reflect.TypeOf([]int64{})
+	case reflect.Uint, reflect.Uintptr:
+		return reflect.TypeOf([]uint64{})
+	}
+	return reflect.SliceOf(t)
+}
+
+// Follow a field index into a struct value.
+// Returns false if it passes through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+type columnarEncoder struct {
+	fields structFields
+	types  []reflect.Type // Column type of each field
+}
+
+func (ce columnarEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.out().WriteNil()
+		return
+	}
+
+	n := v.Len()
+	e.out().WriteStructStart()
+
+	for i := range ce.fields.list {
+		f := &ce.fields.list[i]
+
+		col := reflect.MakeSlice(ce.types[i], n, n)
+		for j := 0; j < n; j++ {
+			// Fields behind nil embedded pointers are left as zero values.
+			if fv, ok := fieldByIndex(v.Index(j), f.index); ok {
+				col.Index(j).Set(fv.Convert(ce.types[i].Elem()))
+			}
+		}
+
+		e.out().WriteString(f.nameNonEsc)
+		e.reflectValue(col, opts)
+	}
+
+	e.out().WriteStructEnd()
+}
+
+func newColumnarEncoder(t reflect.Type) encoderFunc {
+	if t.Kind() == reflect.Pointer {
+		enc := ptrEncoder{newColumnarEncoder(t.Elem())}
+		return enc.encode
+	}
+
+	// The element fields are looked up on first use, as the element type
+	// may be the struct being built.
+	var (
+		once sync.Once
+		enc  encoderFunc
+	)
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		once.Do(func() {
+			ce := columnarEncoder{fields: cachedTypeFields(t.Elem())}
+			if name, opt := columnarOption(&ce.fields); opt != "" {
+				err := &ColumnarOptionError{Type: t, Field: name, Option: opt}
+				enc = func(e *encodeState, _ reflect.Value, _ encOpts) { e.error(err) }
+				return
+			}
+			if len(ce.fields.list) == 0 {
+				// There would be no column to hold the length
+				enc = typeEncoder(t)
+				return
+			}
+			for _, f := range ce.fields.list {
+				ce.types = append(ce.types, columnType(typeByIndex(t.Elem(), f.index)))
+			}
+			enc = ce.encode
+		})
+		enc(e, v, opts)
+	}
+}
+
+// Decode a struct of columns into a slice of structs.
+func (d *decodeState) columns(desc LtvDesc, v reflect.Value) error {
+	_, _, _, v = indirect(v, false)
+	if !isColumnarType(v.Type()) {
+		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		d.skip(desc)
+		return nil
+	}
+
+	elemType := v.Type().Elem()
+	fields := cachedTypeFields(elemType)
+
+	var origErrorContext errorContext
+	if d.errorContext != nil {
+		origErrorContext = *d.errorContext
+	} else {
+		d.errorContext = new(errorContext)
+	}
+
+	type column struct {
+		f   *field
+		col reflect.Value
+	}
+
+	var cols []column
+	n := -1
+	seen := make([]bool, len(fields.list))
+
+	for {
+		desc, err := d.decoder.Next()
+		if err != nil {
+			return err
+		}
+
+		if desc.TypeCode == End {
+			break
+		}
+
+		keyVal, err := d.decoder.ReadValue(desc)
+		if err != nil {
+			return err
+		}
+		key := keyVal.(string)
+
+		desc, err = d.decoder.Next()
+		if err != nil {
+			return d.addErrorContext(err)
+		}
+
+		f, i := fields.byName(key, d.caseSensitive)
+		switch {
+		case f == nil:
+			d.saveError(&FieldError{Err: ErrUnknownField, Key: key, GoType: elemType})
+			d.skip(desc)
+			continue
+		case d.disallowDups && seen[i]:
+			d.saveError(&FieldError{Err: ErrDuplicateKey, Key: key, GoType: elemType})
+			d.skip(desc)
+			continue
+		}
+		seen[i] = true
+
+		d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
+		d.errorContext.Struct = elemType
+
+		col := reflect.New(reflect.SliceOf(typeByIndex(elemType, f.index))).Elem()
+		if err := d.value(desc, col); err != nil {
+			return err
+		}
+
+		d.errorContext.FieldStack = d.errorContext.FieldStack[:len(origErrorContext.FieldStack)]
+		d.errorContext.Struct = origErrorContext.Struct
+
+		if n < 0 {
+			n = col.Len()
+		} else if col.Len() != n {
+			d.saveError(&RaggedColumnsError{Type: v.Type(), Column: f.name, Len: col.Len(), Want: n})
+			return d.skipStructRemainder()
+		}
+
+		cols = append(cols, column{f, col})
+	}
+
+	if n < 0 {
+		n = 0
+	}
+
+	s := reflect.MakeSlice(v.Type(), n, n)
+	for _, c := range cols {
+		for j := 0; j < n; j++ {
+			fv := s.Index(j)
+			for _, i := range c.f.index {
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						if !fv.CanSet() {
+							d.saveError(fmt.Errorf("ltv: cannot set embedded pointer to unexported struct: %v", fv.Type().Elem()))
+							return nil
+						}
+						fv.Set(reflect.New(fv.Type().Elem()))
+					}
+					fv = fv.Elem()
+				}
+				fv = fv.Field(i)
+			}
+			fv.Set(c.col.Index(j))
+		}
+	}
+
+	if fields.checkMissing {
+		for j := 0; j < n; j++ {
+			d.missingFields(&fields, seen, s.Index(j))
+		}
+	}
+
+	v.Set(s)
+	return nil
+}
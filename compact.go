@@ -0,0 +1,189 @@
+package ltvgo
+
+import (
+	"math"
+	"reflect"
+)
+
+// Compact encoding (MarshalOptions.Compact)
+//
+// Integer slices and arrays are written with the same Goldilocks width
+// selection as scalar integers, so an []int64 with small values is written
+// as an I8 vector. Lists of interfaces holding only integers, only floats or
+// only bools are written as vectors. Unmarshal widens the vectors back into
+// the destination Go types.
+
+// Integer kinds narrowed by the compact encoding.
+// I8 and U8 vectors are already as narrow as they can be.
+func isCompactIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// Wrap an integer slice or array encoder to narrow the vector in compact mode.
+func newCompactIntEncoder(enc encoderFunc) encoderFunc {
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		if !opts.compact || opts.fixedInts {
+			enc(e, v, opts)
+			return
+		}
+
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.out().WriteNil()
+			return
+		}
+
+		switch v.Type().Elem().Kind() {
+		case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+			s := make([]int64, v.Len())
+			for i := range s {
+				s[i] = v.Index(i).Int()
+			}
+			writeFitIntVec(e.out(), s)
+		default:
+			s := make([]uint64, v.Len())
+			for i := range s {
+				s[i] = v.Index(i).Uint()
+			}
+			writeFitUintVec(e.out(), s)
+		}
+	}
+}
+
+// The predeclared types which may be vectorized in an interface list.
+// Named types are excluded, as they may have their own encodings.
+var compactElemTypes = map[reflect.Type]goldiKind{
+	reflect.TypeOf(false):      goldiBool,
+	reflect.TypeOf(int(0)):     goldiInt,
+	reflect.TypeOf(int8(0)):    goldiInt,
+	reflect.TypeOf(int16(0)):   goldiInt,
+	reflect.TypeOf(int32(0)):   goldiInt,
+	reflect.TypeOf(int64(0)):   goldiInt,
+	reflect.TypeOf(uint(0)):    goldiUint,
+	reflect.TypeOf(uint8(0)):   goldiUint,
+	reflect.TypeOf(uint16(0)):  goldiUint,
+	reflect.TypeOf(uint32(0)):  goldiUint,
+	reflect.TypeOf(uint64(0)):  goldiUint,
+	reflect.TypeOf(float32(0)): goldiF32,
+	reflect.TypeOf(float64(0)): goldiF64,
+}
+
+type goldiKind int
+
+const (
+	goldiNone goldiKind = iota
+	goldiBool
+	goldiUint
+	goldiInt
+	goldiF32
+	goldiF64
+)
+
+// Write a list of interfaces as a vector if every element holds the same
+// kind of value. Signed and unsigned integers may be mixed if they fit an
+// int64, and float32 values are widened if mixed with float64 values.
+// Returns false, having written nothing, if the list can't be a vector.
+func (e *encodeState) compactList(v reflect.Value) bool {
+	n := v.Len()
+	if n == 0 {
+		return false
+	}
+
+	kind := goldiNone
+	negative := false
+	var uMax uint64
+
+	for i := 0; i < n; i++ {
+		elem := v.Index(i)
+		if elem.IsNil() {
+			return false
+		}
+		elem = elem.Elem()
+
+		k, ok := compactElemTypes[elem.Type()]
+		if !ok {
+			return false
+		}
+
+		switch k {
+		case goldiInt:
+			if elem.Int() < 0 {
+				negative = true
+			}
+		case goldiUint:
+			if elem.Uint() > uMax {
+				uMax = elem.Uint()
+			}
+		}
+
+		switch {
+		case kind == goldiNone || kind == k:
+			kind = k
+		case (kind == goldiInt && k == goldiUint) || (kind == goldiUint && k == goldiInt):
+			kind = goldiInt
+		case (kind == goldiF32 && k == goldiF64) || (kind == goldiF64 && k == goldiF32):
+			kind = goldiF64
+		default:
+			return false
+		}
+	}
+
+	// Unsigned values that don't fit alongside negative values
+	if negative && uMax > math.MaxInt64 {
+		return false
+	}
+
+	switch kind {
+	case goldiBool:
+		s := make([]bool, n)
+		for i := range s {
+			s[i] = v.Index(i).Elem().Bool()
+		}
+		e.out().WriteBoolVec(s)
+
+	case goldiUint, goldiInt:
+		if !negative {
+			s := make([]uint64, n)
+			for i := range s {
+				elem := v.Index(i).Elem()
+				if elem.CanUint() {
+					s[i] = elem.Uint()
+				} else {
+					s[i] = uint64(elem.Int())
+				}
+			}
+			writeFitUintVec(e.out(), s)
+		} else {
+			s := make([]int64, n)
+			for i := range s {
+				elem := v.Index(i).Elem()
+				if elem.CanInt() {
+					s[i] = elem.Int()
+				} else {
+					s[i] = int64(elem.Uint())
+				}
+			}
+			writeFitIntVec(e.out(), s)
+		}
+
+	case goldiF32:
+		s := make([]float32, n)
+		for i := range s {
+			s[i] = float32(v.Index(i).Elem().Float())
+		}
+		e.out().WriteF32Vec(s)
+
+	case goldiF64:
+		s := make([]float64, n)
+		for i := range s {
+			s[i] = v.Index(i).Elem().Float()
+		}
+		e.out().WriteF64Vec(s)
+	}
+
+	return true
+}
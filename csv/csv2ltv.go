@@ -0,0 +1,264 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+var errBadUtf8 = errors.New("csv: cell with invalid UTF-8 data")
+
+// Max integer magnitude that survives conversion to a float64
+const maxSafeInt = 1<<53 - 1
+
+// Decimal float literals. ParseFloat also accepts words such as "NaN" and
+// "Inf", which would turn text columns into floats.
+var floatPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+type columnKind int
+
+// Column kinds in upgrade order: an empty column may become anything,
+// uint columns may become int columns, int columns may become float columns,
+// and anything may collapse to a string column.
+const (
+	emptyColumn columnKind = iota
+	boolColumn
+	uintColumn
+	intColumn
+	floatColumn
+	stringColumn
+)
+
+// A column of CSV cells with its inferred type.
+type column struct {
+	name  string
+	cells []string
+
+	kind    columnKind
+	missing bool // Has empty cells
+
+	uMax uint64
+	iMin int64
+	iMax int64
+}
+
+// Widen the column kind to hold a cell.
+func (c *column) infer(cell string) {
+	if cell == "" {
+		c.missing = true
+		return
+	}
+
+	if c.kind == stringColumn {
+		return
+	}
+
+	var k columnKind
+	if u, err := strconv.ParseUint(cell, 10, 64); err == nil {
+		k = uintColumn
+		if u > c.uMax {
+			c.uMax = u
+		}
+	} else if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		k = intColumn
+		if i < c.iMin {
+			c.iMin = i
+		}
+		if i > c.iMax {
+			c.iMax = i
+		}
+	} else if _, err := strconv.ParseFloat(cell, 64); err == nil && floatPattern.MatchString(cell) {
+		k = floatColumn
+	} else if cell == "true" || cell == "false" {
+		k = boolColumn
+	} else {
+		k = stringColumn
+	}
+
+	c.kind = upgradeKind(c, k)
+}
+
+// Find a column kind compatible with both the current column and k.
+func upgradeKind(c *column, k columnKind) columnKind {
+	if c.kind == emptyColumn || c.kind == k {
+		return k
+	}
+
+	if c.kind == boolColumn || k == boolColumn {
+		return stringColumn
+	}
+
+	if k < c.kind {
+		k = c.kind
+	}
+
+	// Check integer ranges before upgrading
+	switch k {
+	case intColumn:
+		if c.uMax > math.MaxInt64 {
+			return stringColumn
+		}
+	case floatColumn:
+		if c.uMax > maxSafeInt || c.iMin < -maxSafeInt || c.iMax > maxSafeInt {
+			return stringColumn
+		}
+	}
+
+	return k
+}
+
+// CSV to LiteVector converter.
+//
+// The first CSV row is the header. Each column's type is inferred from all
+// of its cells (unsigned, signed, float, bool or string) with the same upgrade
+// rules as the JSON GoldiList. Only decimal literals are floats, so cells
+// such as "NaN" or "Inf" make a string column.
+//
+// By default the output is a list of structs, one per row, using the header
+// names as keys and omitting empty cells. If columnar is true, the output is
+// a single struct of columns. Numeric and bool columns are written as typed
+// vectors, with integer widths fit to the column's range. Empty cells in float
+// columns become NaN, while other columns with empty cells (and string columns)
+// are written as lists.
+func Csv2Ltv(r io.Reader, w io.Writer, columnar bool) error {
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Build and type the columns
+	seen := make(map[string]bool)
+	cols := make([]column, len(rows[0]))
+	for i, name := range rows[0] {
+		if seen[name] {
+			return fmt.Errorf("csv: duplicate column %q", name)
+		}
+		seen[name] = true
+		cols[i].name = name
+	}
+
+	for _, row := range rows[1:] {
+		for i, cell := range row {
+			if !utf8.ValidString(cell) {
+				return errBadUtf8
+			}
+			cols[i].cells = append(cols[i].cells, cell)
+			cols[i].infer(cell)
+		}
+	}
+
+	for i := range cols {
+		if !utf8.ValidString(cols[i].name) {
+			return errBadUtf8
+		}
+	}
+
+	e := ltv.NewStreamEncoder(w)
+	if columnar {
+		writeColumns(e, cols)
+	} else {
+		writeRows(e, cols, len(rows)-1)
+	}
+
+	return e.Werr
+}
+
+// Write a single cell as a scalar of the column's kind.
+func writeCell(e *ltv.StreamEncoder, kind columnKind, cell string) {
+	if cell == "" {
+		e.WriteNil()
+		return
+	}
+
+	switch kind {
+	case boolColumn:
+		e.WriteBool(cell == "true")
+	case uintColumn:
+		u, _ := strconv.ParseUint(cell, 10, 64)
+		e.WriteUint(u)
+	case intColumn:
+		i, _ := strconv.ParseInt(cell, 10, 64)
+		e.WriteInt(i)
+	case floatColumn:
+		f, _ := strconv.ParseFloat(cell, 64)
+		e.WriteF64(f)
+	default:
+		e.WriteString(cell)
+	}
+}
+
+func writeRows(e *ltv.StreamEncoder, cols []column, count int) {
+	e.WriteListStart()
+	for row := 0; row < count; row++ {
+		e.WriteStructStart()
+		for _, c := range cols {
+			if c.cells[row] == "" {
+				continue
+			}
+			e.WriteString(c.name)
+			writeCell(e, c.kind, c.cells[row])
+		}
+		e.WriteStructEnd()
+	}
+	e.WriteListEnd()
+}
+
+func writeColumns(e *ltv.StreamEncoder, cols []column) {
+	e.WriteStructStart()
+	for _, c := range cols {
+		e.WriteString(c.name)
+
+		if (c.missing && c.kind != floatColumn) || c.kind == stringColumn || c.kind == emptyColumn {
+			e.WriteListStart()
+			for _, cell := range c.cells {
+				writeCell(e, c.kind, cell)
+			}
+			e.WriteListEnd()
+			continue
+		}
+
+		switch c.kind {
+		case boolColumn:
+			vec := make([]bool, len(c.cells))
+			for i, cell := range c.cells {
+				vec[i] = cell == "true"
+			}
+			e.WriteBoolVec(vec)
+		case uintColumn:
+			vec := make([]uint64, len(c.cells))
+			for i, cell := range c.cells {
+				vec[i], _ = strconv.ParseUint(cell, 10, 64)
+			}
+			e.WriteUintVec(vec)
+		case intColumn:
+			vec := make([]int64, len(c.cells))
+			for i, cell := range c.cells {
+				vec[i], _ = strconv.ParseInt(cell, 10, 64)
+			}
+			e.WriteIntVec(vec)
+		case floatColumn:
+			vec := make([]float64, len(c.cells))
+			for i, cell := range c.cells {
+				if cell == "" {
+					vec[i] = math.NaN()
+				} else {
+					vec[i], _ = strconv.ParseFloat(cell, 64)
+				}
+			}
+			e.WriteF64Vec(vec)
+		}
+	}
+	e.WriteStructEnd()
+}
@@ -0,0 +1,177 @@
+package csv
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+type reading struct {
+	Name   string
+	Count  int
+	Temp   float64
+	Pos    struct{ X, Y int }
+	Values []int16
+}
+
+func marshalRecords(t *testing.T, records ...any) []byte {
+	var buf []byte
+	for _, r := range records {
+		b, err := ltv.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = append(buf, b...)
+	}
+	return buf
+}
+
+func TestLtv2Csv(t *testing.T) {
+	r1 := reading{Name: "a", Count: 1, Temp: 1.5, Values: []int16{1, 2}}
+	r1.Pos.X = 10
+	r2 := map[string]any{"Name": "b", "Extra": true}
+
+	data := marshalRecords(t, r1, r2)
+
+	var out bytes.Buffer
+	if err := Ltv2Csv(bytes.NewReader(data), &out, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Name,Count,Temp,Pos.X,Pos.Y,Values,Extra\n" +
+		"a,1,1.5,10,0,1 2,\n" +
+		"b,,,,,,true\n"
+	if out.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := Ltv2Csv(bytes.NewReader(data), &out, true); err != nil {
+		t.Fatal(err)
+	}
+
+	want = "Name,Count,Temp,Pos.X,Pos.Y,Values.0,Values.1,Extra\n" +
+		"a,1,1.5,10,0,1,2,\n" +
+		"b,,,,,,,true\n"
+	if out.String() != want {
+		t.Fatalf("unexpected expanded CSV:\n%s", out.String())
+	}
+
+	// Joined elements containing the separator are quoted
+	data = marshalRecords(t, map[string]any{"Tags": []string{"a b", "c"}})
+	out.Reset()
+	if err := Ltv2Csv(bytes.NewReader(data), &out, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Tags\n\"\"\"a b\"\" c\"\n"; out.String() != want {
+		t.Fatalf("unexpected CSV:\n%s", out.String())
+	}
+}
+
+func TestCsv2LtvRows(t *testing.T) {
+	in := "Name,Count,Temp,Ok\n" +
+		"a,1,1.5,true\n" +
+		"b,-2,,false\n"
+
+	var out bytes.Buffer
+	if err := Csv2Ltv(strings.NewReader(in), &out, false); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ltv.NewDecoder(out.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []any{
+		map[string]any{"Name": "a", "Count": int8(1), "Temp": 1.5, "Ok": true},
+		map[string]any{"Name": "b", "Count": int8(-2), "Ok": false},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestCsv2LtvColumnar(t *testing.T) {
+	in := "Name,Count,Big,Temp,Ok,Sparse\n" +
+		"a,1,300,1.5,true,1\n" +
+		"b,-2,70000,,false,\n"
+
+	var out bytes.Buffer
+	if err := Csv2Ltv(strings.NewReader(in), &out, true); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ltv.NewDecoder(out.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := v.(map[string]any)
+	if !reflect.DeepEqual(m["Name"], []any{"a", "b"}) {
+		t.Fatalf("Name: got %#v", m["Name"])
+	}
+	if !reflect.DeepEqual(m["Count"], []int8{1, -2}) {
+		t.Fatalf("Count: got %#v", m["Count"])
+	}
+	if !reflect.DeepEqual(m["Big"], []uint32{300, 70000}) {
+		t.Fatalf("Big: got %#v", m["Big"])
+	}
+	if temp := m["Temp"].([]float64); temp[0] != 1.5 || !math.IsNaN(temp[1]) {
+		t.Fatalf("Temp: got %#v", m["Temp"])
+	}
+	if !reflect.DeepEqual(m["Ok"], []bool{true, false}) {
+		t.Fatalf("Ok: got %#v", m["Ok"])
+	}
+	if !reflect.DeepEqual(m["Sparse"], []any{uint8(1), nil}) {
+		t.Fatalf("Sparse: got %#v", m["Sparse"])
+	}
+
+	// Words ParseFloat accepts are still text
+	out.Reset()
+	if err := Csv2Ltv(strings.NewReader("Word,F\nNaN,1e3\nInf,-.5\n"), &out, true); err != nil {
+		t.Fatal(err)
+	}
+	v, err = ltv.NewDecoder(out.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = v.(map[string]any)
+	if !reflect.DeepEqual(m["Word"], []any{"NaN", "Inf"}) || !reflect.DeepEqual(m["F"], []float64{1000, -0.5}) {
+		t.Fatalf("got %#v", m)
+	}
+}
+
+func TestCsvRoundTrip(t *testing.T) {
+	type row struct {
+		Name  string
+		Count int
+		Temp  float64
+	}
+
+	rows := []row{{"a", 1, 1.5}, {"b", 2, -3}}
+	data := marshalRecords(t, rows)
+
+	var csvBuf bytes.Buffer
+	if err := Ltv2Csv(bytes.NewReader(data), &csvBuf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var ltvBuf bytes.Buffer
+	if err := Csv2Ltv(&csvBuf, &ltvBuf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows2 []row
+	if err := ltv.Unmarshal(ltvBuf.Bytes(), &rows2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rows, rows2) {
+		t.Fatalf("roundtrip mismatch: %v != %v", rows, rows2)
+	}
+}
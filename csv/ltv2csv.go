@@ -0,0 +1,259 @@
+// Package csv converts between flat LiteVector records and CSV.
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+// Separator between vector elements joined into a single cell. The joined
+// elements are written as a CSV record of their own, so elements containing
+// the separator or quotes are quoted, and the cell splits back unambiguously.
+const VectorSeparator = ' '
+
+var errNotRecord = errors.New("csv: expected a struct record")
+
+// A flattened record: column path -> cell value
+type record map[string]string
+
+// Collects flattened records along with the union of their columns
+// in the order they were first seen.
+type table struct {
+	columns  []string
+	colIndex map[string]struct{}
+	records  []record
+	expand   bool
+}
+
+func (t *table) set(rec record, path string, value string) {
+	if _, ok := t.colIndex[path]; !ok {
+		t.colIndex[path] = struct{}{}
+		t.columns = append(t.columns, path)
+	}
+	rec[path] = value
+}
+
+// LiteVector records to CSV converter.
+//
+// The input is a sequence of structs (or lists of structs), one per row.
+// The CSV header is the union of all record keys, so the flattened records
+// are held in memory until the input ends, and written after it. Nested
+// structs are flattened using dotted key paths. Vectors and lists of scalars
+// are either expanded into one column per element ("key.0", "key.1", ...),
+// or joined into a single cell with VectorSeparator.
+func Ltv2Csv(r io.Reader, w io.Writer, expand bool) error {
+
+	s := ltv.NewStreamDecoder(r)
+	t := table{
+		colIndex: make(map[string]struct{}),
+		expand:   expand,
+	}
+
+	for {
+		d, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch d.TypeCode {
+		case ltv.Struct:
+			if err := t.readRecord(s); err != nil {
+				return err
+			}
+		case ltv.List:
+			for {
+				d, err := s.Next()
+				if err != nil {
+					return err
+				}
+				if d.TypeCode == ltv.End {
+					break
+				}
+				if d.TypeCode != ltv.Struct {
+					return errNotRecord
+				}
+				if err := t.readRecord(s); err != nil {
+					return err
+				}
+			}
+		default:
+			return errNotRecord
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.columns); err != nil {
+		return err
+	}
+
+	row := make([]string, len(t.columns))
+	for _, rec := range t.records {
+		for i, col := range t.columns {
+			row[i] = rec[col]
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Read a struct (after its start tag) as a flattened record.
+func (t *table) readRecord(s *ltv.StreamDecoder) error {
+	rec := make(record)
+	if err := t.readStruct(s, rec, ""); err != nil {
+		return err
+	}
+	t.records = append(t.records, rec)
+	return nil
+}
+
+func (t *table) readStruct(s *ltv.StreamDecoder, rec record, prefix string) error {
+	for {
+		d, err := s.Next()
+		if err != nil {
+			return err
+		}
+
+		if d.TypeCode == ltv.End {
+			return nil
+		}
+
+		key, err := s.ReadValue(d)
+		if err != nil {
+			return err
+		}
+
+		d, err = s.Next()
+		if err != nil {
+			return err
+		}
+
+		if err := t.readValue(s, d, rec, prefix+key.(string)); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *table) readValue(s *ltv.StreamDecoder, d ltv.LtvElementDesc, rec record, path string) error {
+
+	switch d.TypeCode {
+	case ltv.Struct:
+		return t.readStruct(s, rec, path+".")
+
+	case ltv.List:
+		var cells []string
+		for i := 0; ; i++ {
+			d, err := s.Next()
+			if err != nil {
+				return err
+			}
+			if d.TypeCode == ltv.End {
+				break
+			}
+
+			if t.expand {
+				if err := t.readValue(s, d, rec, path+"."+strconv.Itoa(i)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if d.TypeCode == ltv.Struct || d.TypeCode == ltv.List || (d.SizeCode != ltv.SizeSingle && d.TypeCode != ltv.String) {
+				return fmt.Errorf("csv: cannot join nested values in column %q", path)
+			}
+
+			val, err := s.ReadValue(d)
+			if err != nil {
+				return err
+			}
+			cells = append(cells, formatScalar(val))
+		}
+
+		if !t.expand {
+			t.set(rec, path, joinCells(cells))
+		}
+		return nil
+	}
+
+	val, err := s.ReadValue(d)
+	if err != nil {
+		return err
+	}
+
+	if d.TypeCode == ltv.String || d.SizeCode == ltv.SizeSingle {
+		t.set(rec, path, formatScalar(val))
+		return nil
+	}
+
+	cells := formatVector(val)
+	if t.expand {
+		for i, cell := range cells {
+			t.set(rec, path+"."+strconv.Itoa(i), cell)
+		}
+	} else {
+		t.set(rec, path, joinCells(cells))
+	}
+
+	return nil
+}
+
+// Join vector elements into a single cell.
+func joinCells(cells []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Comma = VectorSeparator
+	w.Write(cells)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func formatScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatVector(v any) []string {
+	var cells []string
+
+	switch v := v.(type) {
+	case []float32:
+		for _, val := range v {
+			cells = append(cells, strconv.FormatFloat(float64(val), 'g', -1, 32))
+		}
+	case []float64:
+		for _, val := range v {
+			cells = append(cells, strconv.FormatFloat(val, 'g', -1, 64))
+		}
+	default:
+		// Bool and integer vectors
+		rv := reflect.ValueOf(v)
+		for i := 0; i < rv.Len(); i++ {
+			cells = append(cells, fmt.Sprint(rv.Index(i).Interface()))
+		}
+	}
+
+	return cells
+}
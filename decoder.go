@@ -148,6 +148,13 @@ func NewDecoder(buf []byte) *Decoder {
 	}
 }
 
+// Reset the decoder to read buf from the start, reusing its memory.
+func (s *Decoder) Reset(buf []byte) {
+	s.buf = buf
+	s.pos = 0
+	s.nStack = s.nStack[:0]
+}
+
 // Check whether x + y > bound with overflow checking.
 func isInBound(x, y, bound uint64) bool {
 	sum, carry := bits.Add64(x, y, 0)
@@ -559,3 +566,28 @@ func (s *Decoder) readStruct() (map[string]any, error) {
 
 	return m, nil
 }
+
+// A saved decoder position, used to look ahead and rewind.
+type decoderMark struct {
+	pos   int
+	depth int
+	top   TypeCode
+}
+
+// Save the current decoder position.
+func (s *Decoder) mark() decoderMark {
+	m := decoderMark{pos: s.pos, depth: len(s.nStack)}
+	if m.depth > 0 {
+		m.top = s.nStack[m.depth-1]
+	}
+	return m
+}
+
+// Rewind the decoder to a saved position at the same or a lower nesting depth.
+func (s *Decoder) restore(m decoderMark) {
+	s.pos = m.pos
+	s.nStack = s.nStack[:m.depth]
+	if m.depth > 0 {
+		s.nStack[m.depth-1] = m.top
+	}
+}
@@ -0,0 +1,143 @@
+package ltvgo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Required fields and default values.
+//
+// A struct field tagged with the "required" option, for example:
+//
+//	Port int `ltv:"port,required"`
+//
+// makes Unmarshal report a FieldError wrapping ErrMissingField when the key
+// is absent from the encoded struct. A field tagged with a "default" option:
+//
+//	Retries int `ltv:"retries,default=3"`
+//
+// is set to the default value when the key is absent. Defaults are supported
+// for strings, bools, integers, floats, time.Duration (in time.ParseDuration
+// form) and types implementing encoding.TextUnmarshaler, and may not contain
+// commas. A default that can't be parsed is reported by any Unmarshal into
+// the struct, whether or not the key is present.
+// Keys present with a nil value are not considered absent.
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Parse the default value of a field of type t.
+func parseDefault(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	var err error
+	switch {
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		err = v.Addr().Interface().(interface{ UnmarshalText([]byte) error }).UnmarshalText([]byte(s))
+
+	case t == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(s)
+		v.SetInt(int64(d))
+
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			v.SetString(s)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(s)
+			v.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(s, 0, t.Bits())
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			var n uint64
+			n, err = strconv.ParseUint(s, 0, t.Bits())
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(s, t.Bits())
+			v.SetFloat(f)
+		default:
+			err = fmt.Errorf("unsupported type %s", t)
+		}
+	}
+
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("ltv: invalid default %q: %w", s, err)
+	}
+	return v, nil
+}
+
+// The default value to store in a field. Values which may refer to memory,
+// such as a net.IP or big.Int, are parsed again for each use so that
+// decoded structs don't share it.
+func (f *field) defaultValue() (reflect.Value, error) {
+	switch f.defValue.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return f.defValue, nil
+	}
+	return parseDefault(f.defValue.Type(), f.defText)
+}
+
+// Follow a field index into a struct value, allocating nil embedded pointers.
+// Returns false if it passes through a nil pointer to an unexported struct.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// Check the fields of a decoded struct v which were not seen,
+// reporting missing required fields and invalid defaults, and setting defaults.
+func (d *decodeState) missingFields(fields *structFields, seen []bool, v reflect.Value) {
+	for i := range fields.list {
+		f := &fields.list[i]
+		if f.defErr != nil {
+			d.saveError(f.defErr)
+			continue
+		}
+		if seen[i] {
+			continue
+		}
+
+		switch {
+		case f.required:
+			d.saveError(&FieldError{Err: ErrMissingField, Key: f.name, GoType: v.Type()})
+
+		case f.defValue.IsValid():
+			fv, ok := fieldByIndexAlloc(v, f.index)
+			if !ok {
+				continue
+			}
+			def, err := f.defaultValue()
+			if err != nil {
+				d.saveError(err)
+				continue
+			}
+			if fv.Kind() == reflect.Pointer && fv.Type().Elem() == def.Type() {
+				p := reflect.New(def.Type())
+				p.Elem().Set(def)
+				fv.Set(p)
+			} else {
+				fv.Set(def)
+			}
+		}
+	}
+}
@@ -2,15 +2,38 @@ package ltvgo
 
 import (
 	"encoding/binary"
+	"errors"
 	"math"
+	"strconv"
 	"unicode/utf8"
 )
 
 const initialBufferSize = 64
 
+// ErrShortBuffer is reported by a fixed Encoder whose buffer is too small
+// for the data written to it.
+var ErrShortBuffer = errors.New("ltv: short buffer")
+
+// A ShortBufferError reports the buffer size a fixed Encoder would have
+// needed for everything written to it. It wraps ErrShortBuffer.
+type ShortBufferError struct {
+	Needed int
+}
+
+func (e *ShortBufferError) Error() string {
+	return "ltv: short buffer, " + strconv.Itoa(e.Needed) + " bytes needed"
+}
+
+func (e *ShortBufferError) Unwrap() error {
+	return ErrShortBuffer
+}
+
 type Encoder struct {
 	buf     []byte // The buffer holding serialized data
 	scratch [8]byte
+	vec     openVector // Vector started by BeginVector
+	fixed   bool       // Never grow buf beyond its capacity
+	over    int        // Bytes dropped past the end of a fixed buffer
 }
 
 func NewEncoder() *Encoder {
@@ -19,6 +42,17 @@ func NewEncoder() *Encoder {
 	}
 }
 
+// NewFixedEncoder returns an Encoder which writes into the capacity of buf
+// and never allocates. Writes which don't fit are dropped, along with
+// everything after them, and reported by Err. Truncate can roll back to a
+// length that did fit.
+func NewFixedEncoder(buf []byte) *Encoder {
+	return &Encoder{
+		buf:   buf[:0],
+		fixed: true,
+	}
+}
+
 // Return the underlying buffer. This is only
 // valid until the next buffer modification.
 // Callers that want to hold onto this value should make a copy.
@@ -28,13 +62,53 @@ func (e *Encoder) Bytes() []byte {
 
 func (e *Encoder) Reset() {
 	e.buf = e.buf[:0]
+	e.vec = openVector{}
+	e.over = 0
+}
+
+// Len returns the number of bytes written, including any dropped by a fixed
+// Encoder.
+func (e *Encoder) Len() int {
+	return len(e.buf) + e.over
+}
+
+// Err returns a *ShortBufferError if a fixed Encoder has dropped data.
+func (e *Encoder) Err() error {
+	if e.over > 0 {
+		return &ShortBufferError{Needed: e.Len()}
+	}
+	return nil
+}
+
+// Truncate discards all but the first n bytes written, rolling back partial
+// writes. It panics if n is negative or greater than Len. Truncating a fixed
+// Encoder to a length which fit in its buffer clears Err.
+func (e *Encoder) Truncate(n int) {
+	if n < 0 || n > e.Len() {
+		panic("ltv: Encoder truncation out of range")
+	}
+	if n <= len(e.buf) {
+		e.buf = e.buf[:n]
+		e.over = 0
+	} else {
+		e.over = n - len(e.buf)
+	}
+	if e.vec.open && n < e.vec.start {
+		e.vec = openVector{}
+	}
 }
 
 // Grow the buffer to accommodate new data.
-// Returns the index where data should start being written.
+// Returns the index where data should start being written,
+// or -1 if a fixed Encoder drops it.
 func (e *Encoder) grow(n int) int {
 	l := len(e.buf)
 
+	if e.fixed && (e.over > 0 || n > cap(e.buf)-l) {
+		e.over += n
+		return -1
+	}
+
 	if n <= cap(e.buf)-l {
 		// Reslice to expand our length if we can
 		e.buf = e.buf[:l+n]
@@ -48,11 +122,15 @@ func (e *Encoder) grow(n int) int {
 }
 
 func (e *Encoder) RawWriteByte(data byte) {
-	e.buf = append(e.buf, data)
+	if idx := e.grow(1); idx >= 0 {
+		e.buf[idx] = data
+	}
 }
 
 func (e *Encoder) RawWrite(data []byte) {
-	e.buf = append(e.buf, data...)
+	if idx := e.grow(len(data)); idx >= 0 {
+		copy(e.buf[idx:], data)
+	}
 }
 
 // Passthrough write Uint16 endian corrected
@@ -101,7 +179,7 @@ func (e *Encoder) WriteVectorPrefix(t TypeCode, count int) {
 	lenSize := 1 << exp
 
 	// Alignment padding
-	alignmentDelta := (len(e.buf) + 1 + lenSize) & (typeSize - 1)
+	alignmentDelta := (e.Len() + 1 + lenSize) & (typeSize - 1)
 	if alignmentDelta != 0 {
 		paddingLen := typeSize - alignmentDelta
 		for i := 0; i < paddingLen; i++ {
@@ -237,6 +315,16 @@ func (e *Encoder) WriteUint(v uint64) {
 	}
 }
 
+// Write int vector with Goldilocks fitting
+func (e *Encoder) WriteIntVec(v []int64) {
+	writeIntVec(e, v)
+}
+
+// Write uint vector with Goldilocks fitting
+func (e *Encoder) WriteUintVec(v []uint64) {
+	writeUintVec(e, v)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 func (e *Encoder) WriteString(s string) {
@@ -251,8 +339,9 @@ func (e *Encoder) WriteString(s string) {
 		e.RawWriteByte(byte(s[0]))
 	} else {
 		e.WriteVectorPrefix(String, len(s))
-		idx := e.grow(len(s))
-		copy(e.buf[idx:], s)
+		if idx := e.grow(len(s)); idx >= 0 {
+			copy(e.buf[idx:], s)
+		}
 	}
 }
 
@@ -281,6 +370,9 @@ func (e *Encoder) WriteU16Vec(v []uint16) {
 	e.WriteVectorPrefix(U16, len(v))
 	typeSize := typeSizes[U16]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint16(e.buf[idx:idx+typeSize], val)
 		idx += typeSize
@@ -291,6 +383,9 @@ func (e *Encoder) WriteU32Vec(v []uint32) {
 	e.WriteVectorPrefix(U32, len(v))
 	typeSize := typeSizes[U32]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint32(e.buf[idx:idx+typeSize], val)
 		idx += typeSize
@@ -301,6 +396,9 @@ func (e *Encoder) WriteU64Vec(v []uint64) {
 	e.WriteVectorPrefix(U64, len(v))
 	typeSize := typeSizes[U64]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint64(e.buf[idx:idx+typeSize], val)
 		idx += typeSize
@@ -311,6 +409,9 @@ func (e *Encoder) WriteI8Vec(v []int8) {
 	e.WriteVectorPrefix(I8, len(v))
 	typeSize := typeSizes[I8]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		e.buf[idx] = byte(val)
 		idx += typeSize
@@ -321,6 +422,9 @@ func (e *Encoder) WriteI16Vec(v []int16) {
 	e.WriteVectorPrefix(I16, len(v))
 	typeSize := typeSizes[I16]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint16(e.buf[idx:idx+typeSize], uint16(val))
 		idx += typeSize
@@ -331,6 +435,9 @@ func (e *Encoder) WriteI32Vec(v []int32) {
 	e.WriteVectorPrefix(I32, len(v))
 	typeSize := typeSizes[I32]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint32(e.buf[idx:idx+typeSize], uint32(val))
 		idx += typeSize
@@ -341,6 +448,9 @@ func (e *Encoder) WriteI64Vec(v []int64) {
 	e.WriteVectorPrefix(I64, len(v))
 	typeSize := typeSizes[I64]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint64(e.buf[idx:idx+typeSize], uint64(val))
 		idx += typeSize
@@ -351,6 +461,9 @@ func (e *Encoder) WriteF32Vec(v []float32) {
 	e.WriteVectorPrefix(F32, len(v))
 	typeSize := typeSizes[F32]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint32(e.buf[idx:idx+typeSize], math.Float32bits(val))
 		idx += typeSize
@@ -361,6 +474,9 @@ func (e *Encoder) WriteF64Vec(v []float64) {
 	e.WriteVectorPrefix(F64, len(v))
 	typeSize := typeSizes[F64]
 	idx := e.grow(len(v) * typeSize)
+	if idx < 0 {
+		return
+	}
 	for _, val := range v {
 		binary.LittleEndian.PutUint64(e.buf[idx:idx+typeSize], math.Float64bits(val))
 		idx += typeSize
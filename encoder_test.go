@@ -1,7 +1,13 @@
 package ltvgo
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestEncoderSimple(t *testing.T) {
@@ -39,3 +45,256 @@ func TestEncoderReset(t *testing.T) {
 		t.Fatal("Unexpected buf len: ", len(s2))
 	}
 }
+
+func TestEncoderIncrementalVector(t *testing.T) {
+	e := NewEncoder()
+	e.WriteListStart()
+	e.WriteU8(1) // Misalign the vector
+
+	e.BeginVector(F64)
+	for i := 0; i < 1000; i++ {
+		e.AppendF64(float64(i) / 2)
+	}
+	e.EndVector()
+
+	e.BeginVector(String)
+	e.AppendString("hello, ")
+	e.AppendString("world")
+	e.EndVector()
+
+	e.BeginVector(I16)
+	e.EndVector()
+	e.WriteListEnd()
+
+	if err := Validate(e.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := make([]float64, 1000)
+	for i := range want {
+		want[i] = float64(i) / 2
+	}
+
+	var got []any
+	if err := Unmarshal(e.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []any{1, want, "hello, world", []int16{}}) {
+		t.Fatalf("unexpected value: %v", got)
+	}
+
+	// The vector data is aligned
+	d := NewDecoder(e.Bytes())
+	d.Next()
+	desc, _ := d.Next()
+	d.Skip(desc)
+	desc, _ = d.Next()
+	if desc.TypeCode != F64 || d.pos%8 != 0 {
+		t.Fatalf("unaligned vector: %v at %d", desc.TypeCode, d.pos)
+	}
+
+	// Mismatched types panic
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		e.BeginVector(U16)
+		e.AppendU32(1)
+	}()
+}
+
+func TestStreamEncoderIncrementalVector(t *testing.T) {
+	for _, limit := range []int{0, 100} {
+		var buf bytes.Buffer
+		se := NewStreamEncoder(&buf)
+		se.SpoolMemLimit = limit
+		se.SpoolDir = t.TempDir()
+		spooled := false
+
+		se.WriteListStart()
+		se.BeginVector(I32)
+		for i := int32(0); i < 1000; i++ {
+			se.AppendI32(i, -i)
+		}
+		if entries, _ := os.ReadDir(se.SpoolDir); len(entries) > 0 {
+			spooled = true
+		}
+		se.EndVector()
+		se.BeginVector(Bool)
+		se.AppendBool(true, false)
+		se.EndVector()
+		se.WriteListEnd()
+
+		if se.Werr != nil {
+			t.Fatal(se.Werr)
+		}
+		if entries, _ := os.ReadDir(se.SpoolDir); spooled != (limit > 0) || len(entries) > 0 {
+			t.Fatalf("unexpected spooling with limit %d: %v %v", limit, spooled, entries)
+		}
+
+		var vals []any
+		if err := Unmarshal(buf.Bytes(), &vals); err != nil {
+			t.Fatal(err)
+		}
+		ints, ok := vals[0].([]int32)
+		if !ok || len(ints) != 2000 || ints[1999] != -999 {
+			t.Fatalf("unexpected ints: %v", vals[0])
+		}
+		if !reflect.DeepEqual(vals[1], []bool{true, false}) {
+			t.Fatalf("unexpected bools: %v", vals[1])
+		}
+	}
+}
+
+func TestIncrementalVectorUTF8(t *testing.T) {
+	// Runes split across AppendString calls
+	valid := [][]string{
+		{"h\xc3", "\xa9llo"},
+		{"\xe2", "\x82", "\xac"},
+		{"\xf0\x9f", "", "\x98\x80!"},
+	}
+	invalid := [][]string{
+		{"a\xff"},
+		{"\xc3"},
+		{"\xe2\x82", "a"},
+		{"ok", "\xf0\x9f\x98"},
+	}
+
+	for _, parts := range append(valid, invalid...) {
+		want := strings.Join(parts, "")
+		ok := utf8.ValidString(want)
+
+		e := NewEncoder()
+		e.WriteU8(1)
+		e.BeginVector(String)
+		for _, p := range parts {
+			e.AppendString(p)
+		}
+		err := e.EndVector()
+		if ok != (err == nil) {
+			t.Fatalf("%q: unexpected result: %v", want, err)
+		}
+		if !ok && e.Len() != 2 {
+			t.Fatalf("%q: vector not dropped: % x", want, e.Bytes())
+		}
+
+		var buf bytes.Buffer
+		se := NewStreamEncoder(&buf)
+		se.BeginVector(String)
+		for _, p := range parts {
+			se.AppendString(p)
+		}
+		if err := se.EndVector(); ok != (err == nil) || err != se.Werr {
+			t.Fatalf("%q: unexpected stream result: %v", want, err)
+		}
+
+		if ok {
+			var got string
+			if err := Unmarshal(buf.Bytes(), &got); err != nil || got != want {
+				t.Fatalf("%q: unexpected value: %q %v", want, got, err)
+			}
+		}
+	}
+}
+
+func TestFixedEncoder(t *testing.T) {
+	write := func(e *Encoder) {
+		e.WriteStructStart()
+		e.WriteString("gain")
+		e.WriteF32(0.5)
+		e.WriteString("samples")
+		e.WriteI16Vec([]int16{1, -2, 3, -4})
+		e.WriteStructEnd()
+	}
+
+	ref := NewEncoder()
+	write(ref)
+
+	buf := make([]byte, 64)
+	e := NewFixedEncoder(buf)
+	allocs := testing.AllocsPerRun(100, func() {
+		e.Reset()
+		write(e)
+	})
+	if allocs != 0 {
+		t.Errorf("fixed encoder allocated %v times", allocs)
+	}
+	if e.Err() != nil || !bytes.Equal(e.Bytes(), ref.Bytes()) || &e.Bytes()[0] != &buf[0] {
+		t.Fatalf("unexpected encoding: %x %v", e.Bytes(), e.Err())
+	}
+
+	// Too short, reporting the size needed
+	e = NewFixedEncoder(buf[:10:10])
+	write(e)
+	var sbe *ShortBufferError
+	if err := e.Err(); !errors.As(err, &sbe) || !errors.Is(err, ErrShortBuffer) || sbe.Needed != ref.Len() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(e.Bytes()) > 10 || !bytes.HasPrefix(ref.Bytes(), e.Bytes()) {
+		t.Fatalf("unexpected partial encoding: %x", e.Bytes())
+	}
+
+	// Rolling back a partial write
+	e = NewFixedEncoder(buf[:16:16])
+	e.WriteListStart()
+	e.WriteU8(1)
+	mark := e.Len()
+	e.WriteString("this string doesn't fit")
+	if e.Err() == nil {
+		t.Fatal("expected a short buffer")
+	}
+	e.Truncate(mark)
+	if e.Err() != nil {
+		t.Fatal(e.Err())
+	}
+	e.WriteListEnd()
+
+	var got []any
+	if err := Unmarshal(e.Bytes(), &got); err != nil || !reflect.DeepEqual(got, []any{1}) {
+		t.Fatalf("unexpected value: %v %v", got, err)
+	}
+
+	// Incremental vectors in a fixed buffer
+	e = NewFixedEncoder(buf)
+	e.BeginVector(U32)
+	e.AppendU32(1, 2, 3)
+	e.EndVector()
+	var u32s []uint32
+	if err := Unmarshal(e.Bytes(), &u32s); err != nil || !reflect.DeepEqual(u32s, []uint32{1, 2, 3}) {
+		t.Fatalf("unexpected value: %v %v", u32s, err)
+	}
+
+	e.BeginVector(U64)
+	e.AppendU64(make([]uint64, 100)...)
+	e.EndVector()
+	if !errors.Is(e.Err(), ErrShortBuffer) {
+		t.Fatal("expected a short buffer")
+	}
+}
+
+func TestFitVecFallback(t *testing.T) {
+	// Only the LtvEncoder methods, as an external implementation would have
+	type plainEncoder struct{ LtvEncoder }
+
+	for _, v := range [][]int64{{1, -2}, {1, -300}, {1 << 20}, {-1 << 40}} {
+		want := NewEncoder()
+		want.WriteIntVec(v)
+		got := NewEncoder()
+		writeFitIntVec(plainEncoder{got}, v)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("%v: %x != %x", v, got.Bytes(), want.Bytes())
+		}
+	}
+
+	for _, v := range [][]uint64{{1, 2}, {300}, {1 << 20}, {1 << 40}} {
+		want := NewEncoder()
+		want.WriteUintVec(v)
+		got := NewEncoder()
+		writeFitUintVec(plainEncoder{got}, v)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("%v: %x != %x", v, got.Bytes(), want.Bytes())
+		}
+	}
+}
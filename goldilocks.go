@@ -0,0 +1,164 @@
+package ltvgo
+
+import "math"
+
+type signedInt interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+type unsignedInt interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// The subset of encoder methods needed to hand write vector data.
+type vectorWriter interface {
+	WriteVectorPrefix(TypeCode, int)
+	RawWriteByte(byte)
+	RawWriteUint16(uint16)
+	RawWriteUint32(uint32)
+	RawWriteUint64(uint64)
+}
+
+// Find the smallest signed type that can hold every value in v.
+func fitIntVec[T signedInt](v []T) TypeCode {
+	var iMin, iMax int64
+	for _, val := range v {
+		if int64(val) < iMin {
+			iMin = int64(val)
+		}
+		if int64(val) > iMax {
+			iMax = int64(val)
+		}
+	}
+
+	switch {
+	case iMin >= math.MinInt8 && iMax <= math.MaxInt8:
+		return I8
+	case iMin >= math.MinInt16 && iMax <= math.MaxInt16:
+		return I16
+	case iMin >= math.MinInt32 && iMax <= math.MaxInt32:
+		return I32
+	default:
+		return I64
+	}
+}
+
+// Find the smallest unsigned type that can hold every value in v.
+func fitUintVec[T unsignedInt](v []T) TypeCode {
+	var uMax uint64
+	for _, val := range v {
+		if uint64(val) > uMax {
+			uMax = uint64(val)
+		}
+	}
+
+	switch {
+	case uMax <= math.MaxUint8:
+		return U8
+	case uMax <= math.MaxUint16:
+		return U16
+	case uMax <= math.MaxUint32:
+		return U32
+	default:
+		return U64
+	}
+}
+
+// Write a signed integer vector with Goldilocks fitting.
+func writeIntVec[T signedInt](w vectorWriter, v []T) {
+	t := fitIntVec(v)
+	w.WriteVectorPrefix(t, len(v))
+
+	switch t {
+	case I8:
+		for _, val := range v {
+			w.RawWriteByte(byte(val))
+		}
+	case I16:
+		for _, val := range v {
+			w.RawWriteUint16(uint16(val))
+		}
+	case I32:
+		for _, val := range v {
+			w.RawWriteUint32(uint32(val))
+		}
+	default:
+		for _, val := range v {
+			w.RawWriteUint64(uint64(val))
+		}
+	}
+}
+
+// Write an unsigned integer vector with Goldilocks fitting.
+func writeUintVec[T unsignedInt](w vectorWriter, v []T) {
+	t := fitUintVec(v)
+	w.WriteVectorPrefix(t, len(v))
+
+	switch t {
+	case U8:
+		for _, val := range v {
+			w.RawWriteByte(byte(val))
+		}
+	case U16:
+		for _, val := range v {
+			w.RawWriteUint16(uint16(val))
+		}
+	case U32:
+		for _, val := range v {
+			w.RawWriteUint32(uint32(val))
+		}
+	default:
+		for _, val := range v {
+			w.RawWriteUint64(uint64(val))
+		}
+	}
+}
+
+// Convert the elements of an integer vector.
+func convertVec[D, S signedInt | unsignedInt](v []S) []D {
+	out := make([]D, len(v))
+	for i, val := range v {
+		out[i] = D(val)
+	}
+	return out
+}
+
+// Write a signed integer vector with Goldilocks fitting to any encoder,
+// preferring its own WriteIntVec.
+func writeFitIntVec(e LtvEncoder, v []int64) {
+	if ie, ok := e.(IntVecEncoder); ok {
+		ie.WriteIntVec(v)
+		return
+	}
+
+	switch fitIntVec(v) {
+	case I8:
+		e.WriteI8Vec(convertVec[int8](v))
+	case I16:
+		e.WriteI16Vec(convertVec[int16](v))
+	case I32:
+		e.WriteI32Vec(convertVec[int32](v))
+	default:
+		e.WriteI64Vec(v)
+	}
+}
+
+// Write an unsigned integer vector with Goldilocks fitting to any encoder,
+// preferring its own WriteUintVec.
+func writeFitUintVec(e LtvEncoder, v []uint64) {
+	if ie, ok := e.(IntVecEncoder); ok {
+		ie.WriteUintVec(v)
+		return
+	}
+
+	switch fitUintVec(v) {
+	case U8:
+		e.WriteU8Vec(convertVec[uint8](v))
+	case U16:
+		e.WriteU16Vec(convertVec[uint16](v))
+	case U32:
+		e.WriteU32Vec(convertVec[uint32](v))
+	default:
+		e.WriteU64Vec(v)
+	}
+}
@@ -3,7 +3,6 @@ package json
 import (
 	"encoding/json"
 	"io"
-	"math"
 	"strconv"
 
 	ltv "github.com/ThadThompson/ltvgo"
@@ -141,45 +140,9 @@ func writeGoldiAsVector(lst *GoldiList, e *ltv.StreamEncoder) {
 		e.WriteF64Vec(data)
 	case []uint64:
 		// Goldilocks the vector type size
-		switch {
-		case lst.uMax <= math.MaxUint8:
-			e.WriteVectorPrefix(ltv.U8, len(data))
-			for _, val := range data {
-				e.RawWriteByte(byte(val))
-			}
-		case lst.uMax <= math.MaxUint16:
-			e.WriteVectorPrefix(ltv.U16, len(data))
-			for _, val := range data {
-				e.RawWriteUint16(uint16(val))
-			}
-		case lst.uMax <= math.MaxUint32:
-			e.WriteVectorPrefix(ltv.U32, len(data))
-			for _, val := range data {
-				e.RawWriteUint32(uint32(val))
-			}
-		default:
-			e.WriteU64Vec(data)
-		}
+		e.WriteUintVec(data)
 	case []int64:
-		switch {
-		case lst.iMin >= math.MinInt8 && lst.iMax <= math.MaxInt8:
-			e.WriteVectorPrefix(ltv.I8, len(data))
-			for _, val := range data {
-				e.RawWriteByte(byte(val))
-			}
-		case lst.iMin >= math.MinInt16 && lst.iMax <= math.MaxInt16:
-			e.WriteVectorPrefix(ltv.I16, len(data))
-			for _, val := range data {
-				e.RawWriteUint16(uint16(val))
-			}
-		case lst.iMin >= math.MinInt32 && lst.iMax <= math.MaxInt32:
-			e.WriteVectorPrefix(ltv.I32, len(data))
-			for _, val := range data {
-				e.RawWriteUint32(uint32(val))
-			}
-		default:
-			e.WriteI64Vec(data)
-		}
+		e.WriteIntVec(data)
 
 	default:
 		panic("Unexpected Goldilist data type")
@@ -0,0 +1,89 @@
+package ltvgo
+
+import (
+	"errors"
+	"io"
+)
+
+var errExpectedList = errors.New("ltv: expected a list")
+
+// A ListReader decodes the elements of a list from a stream one at a time,
+// so a large list of records can be processed in constant memory:
+//
+//	r := ltvgo.NewListReader[Record](ltvgo.NewStreamDecoder(f))
+//	for r.Next() {
+//		process(r.Value())
+//	}
+//	if err := r.Err(); err != nil {
+//		...
+//	}
+//
+// Elements are decoded with the StreamDecoder's Decode and Options.
+type ListReader[T any] struct {
+	d       *StreamDecoder
+	value   T
+	err     error
+	started bool
+	done    bool
+}
+
+// NewListReader returns a reader for the list which is the next value of d.
+func NewListReader[T any](d *StreamDecoder) *ListReader[T] {
+	return &ListReader[T]{d: d}
+}
+
+// Next decodes the next element of the list, returning false at the end
+// of the list or on an error.
+func (r *ListReader[T]) Next() bool {
+	if r.err != nil || r.done {
+		return false
+	}
+
+	if !r.started {
+		r.started = true
+
+		desc, err := r.d.Next()
+		for err == nil && r.d.ReturnNops && desc.Tag == NopTag {
+			desc, err = r.d.Next()
+		}
+		if err != nil {
+			r.err = err
+			return false
+		}
+		if desc.TypeCode != List {
+			r.err = errExpectedList
+			return false
+		}
+	}
+
+	if !r.d.More() {
+		// Consume the end of the list, or report why there isn't one
+		if _, err := r.d.Next(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			r.err = err
+		}
+		r.done = true
+		return false
+	}
+
+	var value T
+	if err := r.d.Decode(&value); err != nil {
+		r.err = err
+		return false
+	}
+
+	r.value = value
+	return true
+}
+
+// Value returns the element decoded by the last call to Next.
+func (r *ListReader[T]) Value() T {
+	return r.value
+}
+
+// Err returns the error which stopped Next, if any.
+func (r *ListReader[T]) Err() error {
+	return r.err
+}
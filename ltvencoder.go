@@ -38,3 +38,13 @@ type LtvEncoder interface {
 	WriteF32Vec([]float32)
 	WriteF64Vec([]float64)
 }
+
+// An IntVecEncoder is an LtvEncoder which can also write integer vectors
+// with Goldilocks fitting, as the narrowest type that holds every element.
+// Encoder and StreamEncoder implement it. Other LtvEncoders needn't: the
+// fitted vector is written with their typed vector methods instead.
+type IntVecEncoder interface {
+	LtvEncoder
+	WriteIntVec([]int64)
+	WriteUintVec([]uint64)
+}
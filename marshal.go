@@ -11,6 +11,8 @@
 package ltvgo
 
 import (
+	"bytes"
+	"context"
 	"encoding"
 	"fmt"
 	"reflect"
@@ -19,25 +21,144 @@ import (
 	"strings"
 	"sync"
 	"unicode"
+	"unsafe"
 )
 
+// Marshal returns the LiteVector encoding of v.
+//
+// Struct fields are named and configured by their "ltv" tag, which has the
+// format of encoding/json's "json" tag plus the options of this package.
+// A field without an "ltv" tag uses its "json" tag instead. The two are not
+// merged: an "ltv" tag, even an empty one, replaces the "json" tag entirely,
+// name and options alike.
 func Marshal(v any) ([]byte, error) {
+	return MarshalOptions{}.Marshal(v)
+}
+
+// MarshalOptions configures a Marshal call.
+type MarshalOptions struct {
+	// Codecs used in preference to those registered with RegisterCodec.
+	Codecs *Codecs
+
+	// Use native encodings for time.Time, time.Duration, big.Int,
+	// netip.Addr and net.IP rather than strings and bare integers.
+	WellKnownTypes bool
+
+	// Write integer slices and arrays as vectors of the narrowest type
+	// that holds every element, and write homogeneous []any lists of
+	// integers, floats or bools as vectors.
+	Compact bool
+
+	// Write integers at their declared Go width (int and uint as 64 bits)
+	// rather than the narrowest type that holds the value. This takes
+	// precedence over Compact. The "fixed" tag option enables this for
+	// a single integer struct field; it does not apply to the contents of
+	// nested structs, slices or maps.
+	FixedWidthInts bool
+
+	// Write pointers reached more than once as an identified value
+	// followed by references to it, rather than repeating the value
+	// (or failing on a cycle). See IDKey.
+	References bool
+}
+
+func (o MarshalOptions) encOpts() encOpts {
+	return encOpts{
+		codecs:    o.Codecs,
+		wellKnown: o.WellKnownTypes,
+		compact:   o.Compact,
+		fixedInts: o.FixedWidthInts,
+		refs:      o.References,
+	}
+}
+
+// Marshal v with the given options.
+func (o MarshalOptions) Marshal(v any) ([]byte, error) {
 	e := newEncodeState()
 	defer encodeStatePool.Put(e)
 
-	err := e.marshal(v, encOpts{})
+	err := e.marshal(v, o.encOpts())
 	if err != nil {
 		return nil, err
 	}
-	buf := append([]byte(nil), e.l.Bytes()...)
+
+	// Copy out of the pooled buffer into one of the exact size
+	buf := make([]byte, e.buf.Len())
+	copy(buf, e.buf.Bytes())
 
 	return buf, nil
 }
 
+// MarshalAppend appends the encoding of v to dst, as Marshal would, and
+// returns the extended buffer. Vectors are aligned relative to the start
+// of dst. This doesn't allocate beyond growing dst, so callers can reuse
+// a buffer across messages.
+func MarshalAppend(dst []byte, v any) ([]byte, error) {
+	return MarshalOptions{}.MarshalAppend(dst, v)
+}
+
+// MarshalAppend appends the encoding of v to dst with the given options.
+func (o MarshalOptions) MarshalAppend(dst []byte, v any) ([]byte, error) {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+
+	// Encode into dst, keeping the pooled buffer for the next use,
+	// and not holding onto dst in the pool, even if encoding panics
+	pooled := e.buf.buf
+	defer func() { e.buf.buf = pooled }()
+	e.buf.buf = dst
+	err := e.marshal(v, o.encOpts())
+	if err != nil {
+		return dst, err
+	}
+
+	return e.buf.buf, nil
+}
+
+// MarshalTo writes the encoding of v to enc, as Marshal would. On an
+// error, including a short fixed buffer, whatever was written is
+// truncated away.
+func MarshalTo(enc *Encoder, v any) error {
+	return MarshalOptions{}.MarshalTo(enc, v)
+}
+
+// MarshalTo writes the encoding of v to enc with the given options.
+func (o MarshalOptions) MarshalTo(enc *Encoder, v any) error {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+
+	start := enc.Len()
+	e.l = enc
+	err := e.marshal(v, o.encOpts())
+	e.l = &e.buf
+	if err == nil {
+		err = enc.Err()
+	}
+	if err != nil {
+		enc.Truncate(start)
+		return err
+	}
+
+	return nil
+}
+
+// Encode writes the LiteVector encoding of v to the stream,
+// as Marshal would with the encoder's Options.
+func (s *StreamEncoder) Encode(v any) error {
+	return s.EncodeContext(context.Background(), v)
+}
+
 type Marshaler interface {
 	MarshalLTV() ([]byte, error)
 }
 
+// MarshalerTo is the interface implemented by types that can write
+// themselves directly to an encoder. It is preferred over Marshaler.
+// MarshalLTVTo must write exactly one value.
+type MarshalerTo interface {
+	MarshalLTVTo(LtvEncoder) error
+}
+
 // An UnsupportedTypeError is returned by Marshal when attempting
 // to encode an unsupported value type.
 type UnsupportedTypeError struct {
@@ -79,10 +200,19 @@ func (e *MarshalerError) Error() string {
 // Unwrap returns the underlying error.
 func (e *MarshalerError) Unwrap() error { return e.Err }
 
-// An encodeState encodes LiteVectors into a bytes.Buffer.
+// The encoder methods used by Marshal.
+// This is satisfied by Encoder, StreamEncoder and CountingEncoder.
+type ltvWriter interface {
+	LtvEncoder
+	RawWrite([]byte)
+}
+
+// An encodeState encodes LiteVectors into an Encoder buffer,
+// or another ltvWriter.
 type encodeState struct {
-	l Encoder
-	//buf *bytes.Buffer // accumulated output
+	l   *Encoder  // Output, unless alt is set
+	alt ltvWriter // Stream or counting output in place of l
+	buf Encoder   // accumulated output, when l is &buf
 
 	// Keep track of what pointers we've seen in the current recursive call
 	// path, to avoid cycles that could lead to a stack overflow. Only do
@@ -91,6 +221,8 @@ type encodeState struct {
 	// reasonable amount of nested pointers deep.
 	ptrLevel uint
 	ptrSeen  map[any]struct{}
+
+	refs *refState // Shared pointers, with MarshalOptions.References
 }
 
 const startDetectingCyclesAfter = 1000
@@ -101,9 +233,9 @@ func newEncodeState() *encodeState {
 	if v := encodeStatePool.Get(); v != nil {
 		e := v.(*encodeState)
 
-		e.l.Reset()
-		// e.buf.Reset()
-		// e.l = NewStreamEncoder(e.buf)
+		e.buf.Reset()
+		e.l = &e.buf
+		e.alt = nil
 		if len(e.ptrSeen) > 0 {
 			panic("ptrEncoder.encode should have emptied ptrSeen via defers")
 		}
@@ -111,7 +243,17 @@ func newEncodeState() *encodeState {
 		return e
 	}
 
-	return &encodeState{ptrSeen: make(map[any]struct{})}
+	e := &encodeState{ptrSeen: make(map[any]struct{})}
+	e.l = &e.buf
+	return e
+}
+
+// The output: the Encoder l, or the stream or counting alt in its place.
+func (e *encodeState) out() ltvWriter {
+	if e.alt != nil {
+		return e.alt
+	}
+	return e.l
 }
 
 // ltvError is an error wrapper type for internal use only.
@@ -129,7 +271,11 @@ func (e *encodeState) marshal(v any, opts encOpts) (err error) {
 			}
 		}
 	}()
-	e.reflectValue(reflect.ValueOf(v), opts)
+	if opts.refs {
+		e.referencedValue(reflect.ValueOf(v), opts)
+	} else {
+		e.reflectValue(reflect.ValueOf(v), opts)
+	}
 	return nil
 }
 
@@ -138,6 +284,50 @@ func (e *encodeState) error(err error) {
 	panic(ltvError{err})
 }
 
+// An isZeroer reports whether it holds its zero value, for omitzero.
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// The omitzero check for a field type, preferring an IsZero method.
+// Values are checked through their address where possible, so that
+// boxing them in an interface doesn't allocate.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			// A nil interface or a nil pointer in an interface
+			return v.IsNil() ||
+				(v.Elem().Kind() == reflect.Pointer && v.Elem().IsNil()) ||
+				v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() == reflect.Pointer && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.IsNil() || v.Interface().(isZeroer).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if v.CanAddr() {
+				return v.Addr().Interface().(isZeroer).IsZero()
+			}
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				// Temporary copy to take the address of
+				c := reflect.New(v.Type()).Elem()
+				c.Set(v)
+				v = c
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return reflect.Value.IsZero
+}
+
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -161,6 +351,23 @@ func (e *encodeState) reflectValue(v reflect.Value, opts encOpts) {
 }
 
 type encOpts struct {
+	// codecs overrides the registered codecs for this call.
+	codecs *Codecs
+
+	// wellKnown enables the well-known type codecs.
+	wellKnown bool
+
+	// compact narrows integer vectors and vectorizes homogeneous lists.
+	compact bool
+
+	// fixedInts writes integers at their declared Go width.
+	fixedInts bool
+
+	// refs writes shared pointers once, with references to them.
+	refs bool
+
+	// ctx cancels the encoding of channels and iterators, if set.
+	ctx context.Context
 }
 
 type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
@@ -197,13 +404,14 @@ func typeEncoder(t reflect.Type) encoderFunc {
 	}
 
 	// Compute the real encoder and replace the indirect func with it.
-	f = newTypeEncoder(t, true)
+	f = withCodecOverride(t, newTypeEncoder(t, true))
 	wg.Done()
 	encoderCache.Store(t, f)
 	return f
 }
 
 var (
+	marshalerToType   = reflect.TypeOf((*MarshalerTo)(nil)).Elem()
 	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
 	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 )
@@ -211,6 +419,20 @@ var (
 // newTypeEncoder constructs an encoderFunc for a type.
 // The returned encoder only checks CanAddr when allowAddr is true.
 func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	if c := registeredCodec(t); c != nil {
+		return newCodecEncoder(c)
+	}
+	if t.Kind() == reflect.Pointer && registeredCodec(t.Elem()) != nil {
+		return newPtrEncoder(t)
+	}
+
+	if t.Kind() != reflect.Pointer && allowAddr && reflect.PointerTo(t).Implements(marshalerToType) {
+		return newCondAddrEncoder(addrMarshalerToEncoder, newTypeEncoder(t, false))
+	}
+	if t.Implements(marshalerToType) {
+		return marshalerToEncoder
+	}
+
 	// If we have a non-pointer value whose type implements
 	// Marshaler with a value receiver, then we're better off taking
 	// the address of the value - otherwise we end up with an
@@ -239,6 +461,10 @@ func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
 		return float32Encoder
 	case reflect.Float64:
 		return float64Encoder
+	case reflect.Complex64:
+		return complex64Encoder
+	case reflect.Complex128:
+		return complex128Encoder
 	case reflect.String:
 		return stringEncoder
 	case reflect.Interface:
@@ -253,23 +479,69 @@ func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
 		return newArrayEncoder(t)
 	case reflect.Pointer:
 		return newPtrEncoder(t)
+	case reflect.Chan:
+		return newChanEncoder(t)
+	case reflect.Func:
+		if isIterFunc(t) {
+			return newIterEncoder(t)
+		}
+		return unsupportedTypeEncoder
 	default:
 		return unsupportedTypeEncoder
 	}
 }
 
 func invalidValueEncoder(e *encodeState, v reflect.Value, _ encOpts) {
-	e.l.WriteNil()
+	e.out().WriteNil()
+}
+
+func marshalerToEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		e.out().WriteNil()
+		return
+	}
+	m, ok := v.Interface().(MarshalerTo)
+	if !ok {
+		e.out().WriteNil()
+		return
+	}
+	if err := m.MarshalLTVTo(e.out()); err != nil {
+		e.error(&MarshalerError{v.Type(), err, "MarshalLTVTo"})
+	}
+}
+
+func addrMarshalerToEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
+	va := v.Addr()
+	if va.IsNil() {
+		e.out().WriteNil()
+		return
+	}
+	m := va.Interface().(MarshalerTo)
+	if err := m.MarshalLTVTo(e.out()); err != nil {
+		e.error(&MarshalerError{v.Type(), err, "MarshalLTVTo"})
+	}
 }
 
 func marshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
 	if v.Kind() == reflect.Pointer && v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	m, ok := v.Interface().(Marshaler)
 	if !ok {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	b, err := m.MarshalLTV()
@@ -282,13 +554,17 @@ func marshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 		return
 	}
 
-	e.l.RawWrite(b)
+	e.out().RawWrite(b)
 }
 
 func addrMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
 	va := v.Addr()
 	if va.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	m := va.Interface().(Marshaler)
@@ -301,17 +577,21 @@ func addrMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 		return
 	}
 
-	e.l.RawWrite(b)
+	e.out().RawWrite(b)
 }
 
 func textMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
 	if v.Kind() == reflect.Pointer && v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	m, ok := v.Interface().(encoding.TextMarshaler)
 	if !ok {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	b, err := m.MarshalText()
@@ -322,9 +602,13 @@ func textMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 }
 
 func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if e.countingRefs() {
+		e.out().WriteNil()
+		return
+	}
 	va := v.Addr()
 	if va.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	m := va.Interface().(encoding.TextMarshaler)
@@ -336,34 +620,85 @@ func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 }
 
 func boolEncoder(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteBool(v.Bool())
+	e.out().WriteBool(v.Bool())
 }
 
 func intEncoder(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteInt(v.Int())
+	if !opts.fixedInts {
+		e.out().WriteInt(v.Int())
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Int8:
+		e.out().WriteI8(int8(v.Int()))
+	case reflect.Int16:
+		e.out().WriteI16(int16(v.Int()))
+	case reflect.Int32:
+		e.out().WriteI32(int32(v.Int()))
+	default:
+		e.out().WriteI64(v.Int())
+	}
 }
 
 func uintEncoder(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteUint(v.Uint())
+	if !opts.fixedInts {
+		e.out().WriteUint(v.Uint())
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Uint8:
+		e.out().WriteU8(uint8(v.Uint()))
+	case reflect.Uint16:
+		e.out().WriteU16(uint16(v.Uint()))
+	case reflect.Uint32:
+		e.out().WriteU32(uint32(v.Uint()))
+	default:
+		e.out().WriteU64(v.Uint())
+	}
 }
 
 func float32Encoder(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteF32(float32(v.Float()))
+	e.out().WriteF32(float32(v.Float()))
 }
 
 func float64Encoder(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteF64(float64(v.Float()))
+	e.out().WriteF64(float64(v.Float()))
+}
+
+// Complex numbers are written as a [real, imaginary] float vector
+func complex64Encoder(e *encodeState, v reflect.Value, opts encOpts) {
+	c := v.Complex()
+	e.out().WriteF32Vec([]float32{float32(real(c)), float32(imag(c))})
+}
+
+func complex128Encoder(e *encodeState, v reflect.Value, opts encOpts) {
+	c := v.Complex()
+	e.out().WriteF64Vec([]float64{real(c), imag(c)})
 }
 
 func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteString(v.String())
+	e.out().WriteString(v.String())
 }
 
 func interfaceEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
+		return
+	}
+
+	// Name registered types so they can be decoded back into an interface
+	if name, ok := registeredName(v.Elem().Type()); ok {
+		e.out().WriteStructStart()
+		e.out().WriteString(TypeKey)
+		e.out().WriteString(name)
+		e.out().WriteString(ValueKey)
+		e.reflectValue(v.Elem(), opts)
+		e.out().WriteStructEnd()
 		return
 	}
+
 	e.reflectValue(v.Elem(), opts)
 }
 
@@ -378,10 +713,13 @@ type structEncoder struct {
 type structFields struct {
 	list      []field
 	nameIndex map[string]int
+	remain    *field // Catch-all map for unrecognized keys, if any
+
+	checkMissing bool // Some fields are required or have defaults
 }
 
 func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteStructStart()
+	e.out().WriteStructStart()
 FieldLoop:
 	for i := range se.fields.list {
 		f := &se.fields.list[i]
@@ -398,15 +736,27 @@ FieldLoop:
 			fv = fv.Field(i)
 		}
 
-		if f.omitEmpty && isEmptyValue(fv) {
+		if (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && f.isZero(fv)) {
 			continue
 		}
 
-		e.l.WriteString(f.nameNonEsc)
-		f.encoder(e, fv, opts)
+		e.out().WriteString(f.nameNonEsc)
+		if f.fixed {
+			fieldOpts := opts
+			fieldOpts.fixedInts = true
+			f.encoder(e, fv, fieldOpts)
+		} else {
+			f.encoder(e, fv, opts)
+		}
+	}
+
+	if se.fields.remain != nil {
+		if fv, ok := fieldByIndex(v, se.fields.remain.index); ok {
+			e.remain(fv, se.fields.nameIndex, opts)
+		}
 	}
 
-	e.l.WriteStructEnd()
+	e.out().WriteStructEnd()
 }
 
 func newStructEncoder(t reflect.Type) encoderFunc {
@@ -420,7 +770,7 @@ type mapEncoder struct {
 
 func (me mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
@@ -434,7 +784,7 @@ func (me mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		defer delete(e.ptrSeen, ptr)
 	}
 
-	e.l.WriteStructStart()
+	e.out().WriteStructStart()
 
 	// Extract and sort the keys.
 	sv := make([]reflectWithString, v.Len())
@@ -452,7 +802,7 @@ func (me mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.string(kv.ks)
 		me.elemEnc(e, kv.v, opts)
 	}
-	e.l.WriteStructEnd()
+	e.out().WriteStructEnd()
 	e.ptrLevel--
 }
 
@@ -470,158 +820,171 @@ func newMapEncoder(t reflect.Type) encoderFunc {
 	return me.encode
 }
 
+// View the elements of a slice in place. Boxing it with Interface would
+// allocate, and fail for named element types.
+func sliceOf[T any](v reflect.Value) []T {
+	return unsafe.Slice((*T)(v.UnsafePointer()), v.Len())
+}
+
 func encodeBoolSlice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]bool)
-	if !ok {
-		panic("value not a []bool")
-	}
+	s := sliceOf[bool](v)
 
-	e.l.WriteBoolVec(s)
+	e.out().WriteBoolVec(s)
 }
 
 func encodeI8Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]int8)
-	if !ok {
-		panic("value not a []int8")
-	}
+	s := sliceOf[int8](v)
 
-	e.l.WriteI8Vec(s)
+	e.out().WriteI8Vec(s)
 }
 
 func encodeI16Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]int16)
-	if !ok {
-		panic("value not a []int16")
-	}
+	s := sliceOf[int16](v)
 
-	e.l.WriteI16Vec(s)
+	e.out().WriteI16Vec(s)
 }
 
 func encodeI32Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]int32)
-	if !ok {
-		panic("value not a []int32")
-	}
+	s := sliceOf[int32](v)
 
-	e.l.WriteI32Vec(s)
+	e.out().WriteI32Vec(s)
 }
 
 func encodeI64Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]int64)
-	if !ok {
-		panic("value not a []int64")
-	}
+	s := sliceOf[int64](v)
 
-	e.l.WriteI64Vec(s)
+	e.out().WriteI64Vec(s)
 }
 
 func encodeU8Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]uint8)
-	if !ok {
-		panic("value not a []uint8")
-	}
+	s := sliceOf[uint8](v)
 
-	e.l.WriteU8Vec(s)
+	e.out().WriteU8Vec(s)
 }
 
 func encodeU16Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]uint16)
-	if !ok {
-		panic("value not a []uint16")
-	}
+	s := sliceOf[uint16](v)
 
-	e.l.WriteU16Vec(s)
+	e.out().WriteU16Vec(s)
 }
 
 func encodeU32Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]uint32)
-	if !ok {
-		panic("value not a []uint32")
-	}
+	s := sliceOf[uint32](v)
 
-	e.l.WriteU32Vec(s)
+	e.out().WriteU32Vec(s)
 }
 
 func encodeU64Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]uint64)
-	if !ok {
-		panic("value not a []uint64")
-	}
+	s := sliceOf[uint64](v)
 
-	e.l.WriteU64Vec(s)
+	e.out().WriteU64Vec(s)
 }
 
 func encodeF32Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]float32)
-	if !ok {
-		panic("value not a []float32")
-	}
+	s := sliceOf[float32](v)
 
-	e.l.WriteF32Vec(s)
+	e.out().WriteF32Vec(s)
 }
 
 func encodeF64Slice(e *encodeState, v reflect.Value, _ encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 
-	s, ok := v.Interface().([]float64)
-	if !ok {
-		panic("value not a []float64")
+	s := sliceOf[float64](v)
+
+	e.out().WriteF64Vec(s)
+}
+
+// Complex slices and arrays are written as interleaved
+// [real, imaginary, ...] float vectors.
+func interleaveC64(v reflect.Value) []float32 {
+	s := make([]float32, 2*v.Len())
+	for i := 0; i < v.Len(); i++ {
+		c := v.Index(i).Complex()
+		s[2*i] = float32(real(c))
+		s[2*i+1] = float32(imag(c))
+	}
+	return s
+}
+
+func interleaveC128(v reflect.Value) []float64 {
+	s := make([]float64, 2*v.Len())
+	for i := 0; i < v.Len(); i++ {
+		c := v.Index(i).Complex()
+		s[2*i] = real(c)
+		s[2*i+1] = imag(c)
+	}
+	return s
+}
+
+func encodeC64Slice(e *encodeState, v reflect.Value, _ encOpts) {
+	if v.IsNil() {
+		e.out().WriteNil()
+		return
 	}
 
-	e.l.WriteF64Vec(s)
+	e.out().WriteF32Vec(interleaveC64(v))
+}
+
+func encodeC128Slice(e *encodeState, v reflect.Value, _ encOpts) {
+	if v.IsNil() {
+		e.out().WriteNil()
+		return
+	}
+
+	e.out().WriteF64Vec(interleaveC128(v))
 }
 
 // sliceEncoder just wraps an arrayEncoder, checking to make sure the value isn't nil.
@@ -631,7 +994,7 @@ type sliceEncoder struct {
 
 func (se sliceEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
 		return
 	}
 	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
@@ -654,6 +1017,60 @@ func (se sliceEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 }
 
 func newSliceEncoder(t reflect.Type) encoderFunc {
+	list := sliceEncoder{arrayEncoder{typeEncoder(t.Elem())}.encode}
+	return newVectorOrListEncoder(t, newVectorSliceEncoder, list.encode)
+}
+
+// Slices and arrays share the choice between a typed vector and a list,
+// so a [N]T is written as a []T would be.
+func newVectorOrListEncoder(t reflect.Type, newVec func(reflect.Type) encoderFunc, list encoderFunc) encoderFunc {
+	elem := t.Elem()
+	if !isVectorElem(elem) {
+		return list
+	}
+
+	enc := newVec(t)
+	if isCompactIntKind(elem.Kind()) {
+		if enc == nil {
+			enc = list
+		}
+		enc = newCompactIntEncoder(enc)
+	}
+	if enc == nil {
+		return list
+	}
+	return withElemCodecOverride(elem, enc, list)
+}
+
+// Whether slices and arrays of t may be written as typed vectors of the
+// raw element bits. Elements with a marshaler or a registered codec are
+// written one at a time with their own encoding.
+func isVectorElem(t reflect.Type) bool {
+	if registeredCodec(t) != nil {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	for _, it := range []reflect.Type{marshalerToType, marshalerType, textMarshalerType} {
+		if t.Implements(it) || pt.Implements(it) {
+			return false
+		}
+	}
+	return true
+}
+
+// Wrap a vector encoder to fall back to a list when the per-call codecs
+// have one for the element type.
+func withElemCodecOverride(elem reflect.Type, vec, list encoderFunc) encoderFunc {
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		if (opts.codecs != nil || opts.wellKnown) && opts.lookupCodec(elem) != nil {
+			list(e, v, opts)
+			return
+		}
+		vec(e, v, opts)
+	}
+}
+
+func newVectorSliceEncoder(t reflect.Type) encoderFunc {
 
 	switch t.Elem().Kind() {
 
@@ -682,10 +1099,14 @@ func newSliceEncoder(t reflect.Type) encoderFunc {
 		return encodeF32Slice
 	case reflect.Float64:
 		return encodeF64Slice
+
+	case reflect.Complex64:
+		return encodeC64Slice
+	case reflect.Complex128:
+		return encodeC128Slice
 	}
 
-	enc := sliceEncoder{newArrayEncoder(t)}
-	return enc.encode
+	return nil // Written as a list
 }
 
 type arrayEncoder struct {
@@ -693,17 +1114,125 @@ type arrayEncoder struct {
 }
 
 func (ae arrayEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
-	e.l.WriteListStart()
+	if opts.compact && v.Type().Elem().Kind() == reflect.Interface && e.compactList(v) {
+		return
+	}
+
+	e.out().WriteListStart()
 	n := v.Len()
 	for i := 0; i < n; i++ {
 		ae.elemEnc(e, v.Index(i), opts)
 	}
-	e.l.WriteListEnd()
+	e.out().WriteListEnd()
 }
 
 func newArrayEncoder(t reflect.Type) encoderFunc {
-	enc := arrayEncoder{typeEncoder(t.Elem())}
-	return enc.encode
+	list := arrayEncoder{typeEncoder(t.Elem())}
+	return newVectorOrListEncoder(t, newVectorArrayEncoder, list.encode)
+}
+
+// Arrays of numbers and bools are written as typed vectors.
+func newVectorArrayEncoder(t reflect.Type) encoderFunc {
+	switch t.Elem().Kind() {
+	case reflect.Bool:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]bool, v.Len())
+			for i := range s {
+				s[i] = v.Index(i).Bool()
+			}
+			e.out().WriteBoolVec(s)
+		}
+	case reflect.Int8:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]int8, v.Len())
+			for i := range s {
+				s[i] = int8(v.Index(i).Int())
+			}
+			e.out().WriteI8Vec(s)
+		}
+	case reflect.Int16:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]int16, v.Len())
+			for i := range s {
+				s[i] = int16(v.Index(i).Int())
+			}
+			e.out().WriteI16Vec(s)
+		}
+	case reflect.Int32:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]int32, v.Len())
+			for i := range s {
+				s[i] = int32(v.Index(i).Int())
+			}
+			e.out().WriteI32Vec(s)
+		}
+	case reflect.Int64:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]int64, v.Len())
+			for i := range s {
+				s[i] = v.Index(i).Int()
+			}
+			e.out().WriteI64Vec(s)
+		}
+	case reflect.Uint8:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]uint8, v.Len())
+			for i := range s {
+				s[i] = uint8(v.Index(i).Uint())
+			}
+			e.out().WriteU8Vec(s)
+		}
+	case reflect.Uint16:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]uint16, v.Len())
+			for i := range s {
+				s[i] = uint16(v.Index(i).Uint())
+			}
+			e.out().WriteU16Vec(s)
+		}
+	case reflect.Uint32:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]uint32, v.Len())
+			for i := range s {
+				s[i] = uint32(v.Index(i).Uint())
+			}
+			e.out().WriteU32Vec(s)
+		}
+	case reflect.Uint64:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]uint64, v.Len())
+			for i := range s {
+				s[i] = v.Index(i).Uint()
+			}
+			e.out().WriteU64Vec(s)
+		}
+	case reflect.Float32:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]float32, v.Len())
+			for i := range s {
+				s[i] = float32(v.Index(i).Float())
+			}
+			e.out().WriteF32Vec(s)
+		}
+	case reflect.Float64:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			s := make([]float64, v.Len())
+			for i := range s {
+				s[i] = v.Index(i).Float()
+			}
+			e.out().WriteF64Vec(s)
+		}
+	case reflect.Complex64:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			e.out().WriteF32Vec(interleaveC64(v))
+		}
+	case reflect.Complex128:
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			e.out().WriteF64Vec(interleaveC128(v))
+		}
+	}
+
+	return nil // Written as a list
 }
 
 type ptrEncoder struct {
@@ -712,7 +1241,10 @@ type ptrEncoder struct {
 
 func (pe ptrEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 	if v.IsNil() {
-		e.l.WriteNil()
+		e.out().WriteNil()
+		return
+	}
+	if e.refs != nil && e.refPointer(v, pe.elemEnc, opts) {
 		return
 	}
 	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
@@ -812,12 +1344,12 @@ func (w *reflectWithString) resolve() error {
 
 // NOTE: keep in sync with stringBytes below.
 func (e *encodeState) string(s string) {
-	e.l.WriteString(s)
+	e.out().WriteString(s)
 }
 
 // NOTE: keep in sync with string above.
 func (e *encodeState) stringBytes(s []byte) {
-	e.l.WriteString(string(s))
+	e.out().WriteString(string(s))
 }
 
 // A field represents a single field found in a struct.
@@ -832,7 +1364,17 @@ type field struct {
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
+	omitZero  bool
 	quoted    bool
+	columnar  bool // Slice of structs encoded as a struct of vectors
+	fixed     bool // Integers encoded at their declared width
+	required  bool // Unmarshal fails if the key is absent
+
+	defValue reflect.Value // Value stored if the key is absent, if valid
+	defText  string        // The default value as tagged
+	defErr   error         // Error parsing the default value
+
+	isZero func(reflect.Value) bool // Zero check for omitzero
 
 	encoder encoderFunc
 }
@@ -856,11 +1398,11 @@ func (x byIndex) Less(i, j int) bool {
 	return len(x[i].index) < len(x[j].index)
 }
 
-// tagOptions is the string following a comma in a struct field's "json"
-// tag, or the empty string. It does not include the leading comma.
+// tagOptions is the string following a comma in a struct field's "ltv"
+// or "json" tag, or the empty string. It does not include the leading comma.
 type tagOptions string
 
-// parseTag splits a struct field's json tag into its name and
+// parseTag splits a struct field's tag into its name and
 // comma-separated options.
 func parseTag(tag string) (string, tagOptions) {
 	tag, opt, _ := strings.Cut(tag, ",")
@@ -885,6 +1427,19 @@ func (o tagOptions) Contains(optionName string) bool {
 	return false
 }
 
+// Value returns the value of a "name=value" option.
+func (o tagOptions) Value(optionName string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var opt string
+		opt, s, _ = strings.Cut(s, ",")
+		if name, value, ok := strings.Cut(opt, "="); ok && name == optionName {
+			return value, true
+		}
+	}
+	return "", false
+}
+
 // typeFields returns a list of fields that LiteVectors should recognize for the given type.
 // The algorithm is breadth-first search over the set of structs to include - the top struct
 // and then any reachable anonymous structs.
@@ -902,6 +1457,9 @@ func typeFields(t reflect.Type) structFields {
 	// Fields found.
 	var fields []field
 
+	// Shallowest catch-all field found.
+	var remain *field
+
 	for len(next) > 0 {
 		current, next = next, current[:0]
 		count, nextCount = nextCount, map[reflect.Type]int{}
@@ -930,7 +1488,11 @@ func typeFields(t reflect.Type) structFields {
 					// Ignore unexported non-embedded fields.
 					continue
 				}
-				tag := sf.Tag.Get("json")
+				// An ltv tag replaces the json tag entirely (see Marshal)
+				tag, ok := sf.Tag.Lookup("ltv")
+				if !ok {
+					tag = sf.Tag.Get("json")
+				}
 				if tag == "-" {
 					continue
 				}
@@ -961,6 +1523,25 @@ func typeFields(t reflect.Type) structFields {
 					}
 				}
 
+				// Only integer fields are written at a fixed width, so the
+				// option doesn't reach into nested values.
+				fixed := false
+				if opts.Contains("fixed") {
+					switch ft.Kind() {
+					case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+						fixed = true
+					}
+				}
+
+				// Record the catch-all field for unrecognized keys.
+				if (opts.Contains("remain") || opts.Contains("inline")) && isRemainType(sf.Type) {
+					if remain == nil {
+						remain = &field{name: sf.Name, index: index, typ: sf.Type}
+					}
+					continue
+				}
+
 				// Record found field and index sequence.
 				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
 					tagged := name != ""
@@ -973,10 +1554,21 @@ func typeFields(t reflect.Type) structFields {
 						index:     index,
 						typ:       ft,
 						omitEmpty: opts.Contains("omitempty"),
+						omitZero:  opts.Contains("omitzero"),
 						quoted:    quoted,
+						columnar:  opts.Contains("columnar") && isColumnarType(ft),
+						fixed:     fixed,
+						required:  opts.Contains("required"),
+					}
+					if field.omitZero {
+						field.isZero = zeroFunc(sf.Type)
+					}
+					if def, ok := opts.Value("default"); ok {
+						field.defText = def
+						field.defValue, field.defErr = parseDefault(ft, def)
 					}
 					field.nameBytes = []byte(field.name)
-					//field.equalFold = foldFunc(field.nameBytes)
+					field.equalFold = bytes.EqualFold
 
 					field.nameNonEsc = field.name
 
@@ -1050,13 +1642,23 @@ func typeFields(t reflect.Type) structFields {
 
 	for i := range fields {
 		f := &fields[i]
-		f.encoder = typeEncoder(typeByIndex(t, f.index))
+		if f.columnar {
+			f.encoder = newColumnarEncoder(typeByIndex(t, f.index))
+		} else {
+			f.encoder = typeEncoder(typeByIndex(t, f.index))
+		}
 	}
 	nameIndex := make(map[string]int, len(fields))
 	for i, field := range fields {
 		nameIndex[field.name] = i
 	}
-	return structFields{fields, nameIndex}
+	checkMissing := false
+	for _, field := range fields {
+		if field.required || field.defValue.IsValid() || field.defErr != nil {
+			checkMissing = true
+		}
+	}
+	return structFields{fields, nameIndex, remain, checkMissing}
 }
 
 // dominantField looks through the fields, all of which are known to
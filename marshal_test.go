@@ -2,9 +2,18 @@ package ltvgo
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"net"
+	"net/netip"
 	"reflect"
+	"sort"
 	"testing"
+	"time"
 )
 
 func TestRoundString(t *testing.T) {
@@ -197,3 +206,2053 @@ func TestRoundStruct(t *testing.T) {
 		t.Fatal("v1 and v2 are not DeepEqual")
 	}
 }
+
+func TestColumnar(t *testing.T) {
+	type Reading struct {
+		T    int64
+		X, Y float32
+		Ok   bool `ltv:"ok"`
+		Note string
+	}
+	type Log struct {
+		Name     string    `json:"name"`
+		Readings []Reading `ltv:"readings,columnar"`
+	}
+
+	v1 := Log{
+		Name: "log",
+		Readings: []Reading{
+			{T: 1, X: 1.5, Y: -2, Ok: true, Note: "a"},
+			{T: 2, X: 2.5, Y: -3, Note: "b"},
+		},
+	}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generic any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"name": "log",
+		"readings": map[string]any{
+			"T":    []int64{1, 2},
+			"X":    []float32{1.5, 2.5},
+			"Y":    []float32{-2, -3},
+			"ok":   []bool{true, false},
+			"Note": []any{"a", "b"},
+		},
+	}
+	if !reflect.DeepEqual(generic, want) {
+		t.Fatalf("unexpected columnar encoding: %#v", generic)
+	}
+
+	var v2 Log
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatal("roundtrip mismatch")
+	}
+
+	// The list of structs form is still accepted
+	type plainLog struct {
+		Name     string    `json:"name"`
+		Readings []Reading `ltv:"readings"`
+	}
+	enc, err = Marshal(plainLog(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v3 Log
+	if err := Unmarshal(enc, &v3); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v3) {
+		t.Fatal("list form mismatch")
+	}
+
+	// Ragged columns
+	enc, err = Marshal(map[string]any{
+		"readings": map[string]any{
+			"T": []int64{1, 2},
+			"X": []float32{1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v4 Log
+	err = Unmarshal(enc, &v4)
+	if _, ok := err.(*RaggedColumnsError); !ok {
+		t.Fatalf("expected RaggedColumnsError, got %v", err)
+	}
+
+	// Pointers to slices, and structs without fields, which keep their length
+	type empty struct{ hidden int }
+	type ptrLog struct {
+		Readings *[]Reading `ltv:"readings,columnar"`
+		Nil      *[]Reading `ltv:",columnar"`
+		Empty    []empty    `ltv:",columnar"`
+	}
+	v5 := ptrLog{Readings: &v1.Readings, Empty: make([]empty, 3)}
+	if enc, err = Marshal(v5); err != nil {
+		t.Fatal(err)
+	}
+	generic = nil
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := generic.(map[string]any)["readings"].(map[string]any); !ok {
+		t.Fatalf("pointer not columnar: %#v", generic)
+	}
+	var v6 ptrLog
+	if err := Unmarshal(enc, &v6); err != nil {
+		t.Fatal(err)
+	}
+	if v6.Readings == nil || !reflect.DeepEqual(*v6.Readings, v1.Readings) || v6.Nil != nil || len(v6.Empty) != 3 {
+		t.Fatalf("roundtrip mismatch: %+v", v6)
+	}
+
+	// Self referencing element types
+	type tree struct {
+		Name string
+		Kids []tree `ltv:",columnar"`
+	}
+	v7 := tree{Name: "root", Kids: []tree{{Name: "a"}, {Name: "b", Kids: []tree{{Name: "c"}}}}}
+	if enc, err = Marshal(v7); err != nil {
+		t.Fatal(err)
+	}
+	var v8 tree
+	if err := Unmarshal(enc, &v8); err != nil || !reflect.DeepEqual(v7, v8) {
+		t.Fatalf("roundtrip mismatch: %+v %v", v8, err)
+	}
+
+	// Defaults apply to each element of a missing column
+	type defaulted struct {
+		T int64
+		N int `ltv:",default=7"`
+	}
+	type defLog struct {
+		Readings []defaulted `ltv:",columnar"`
+	}
+	enc, _ = Marshal(map[string]any{"Readings": map[string]any{"T": []int64{1, 2}}})
+	var v9 defLog
+	if err := Unmarshal(enc, &v9); err != nil {
+		t.Fatal(err)
+	}
+	if want := []defaulted{{1, 7}, {2, 7}}; !reflect.DeepEqual(v9.Readings, want) {
+		t.Fatalf("got %+v, want %+v", v9.Readings, want)
+	}
+
+	// Options which would write a field differently than in a list
+	type omitted struct {
+		T int64 `ltv:",omitempty"`
+	}
+	type quoted struct {
+		T int64 `ltv:",string"`
+	}
+	type fixed struct {
+		T int64 `ltv:",fixed"`
+	}
+	type remain struct {
+		T     int64
+		Extra map[string]any `ltv:",remain"`
+	}
+	for _, v := range []any{
+		struct {
+			R []omitted `ltv:",columnar"`
+		}{},
+		struct {
+			R []quoted `ltv:",columnar"`
+		}{},
+		struct {
+			R *[]fixed `ltv:",columnar"`
+		}{new([]fixed)},
+		struct {
+			R []remain `ltv:",columnar"`
+		}{},
+	} {
+		_, err := Marshal(v)
+		if _, ok := err.(*ColumnarOptionError); !ok {
+			t.Errorf("%T: expected ColumnarOptionError, got %v", v, err)
+		}
+	}
+}
+
+func TestLtvTagPrecedence(t *testing.T) {
+	type tagged struct {
+		A int `json:"a"`
+		B int `ltv:"b" json:"json_b"`
+		C int `ltv:"" json:"json_c,omitempty"`
+		D int `ltv:"-" json:"d"`
+		E int `ltv:"e,omitempty" json:"e"`
+	}
+
+	enc, err := Marshal(tagged{A: 1, B: 2, D: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := Unmarshal(enc, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"a": 1, "b": 2, "C": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected keys: %v", got)
+	}
+}
+
+type testShape interface {
+	Area() float64
+}
+
+type testSquare struct {
+	Side float64
+}
+
+func (s testSquare) Area() float64 { return s.Side * s.Side }
+
+type testCircle struct {
+	R float64
+}
+
+func (c *testCircle) Area() float64 { return 3 * c.R * c.R }
+
+func TestRegisteredTypes(t *testing.T) {
+	RegisterType("test.square", testSquare{})
+	RegisterType("test.circle", &testCircle{})
+
+	type drawing struct {
+		Main   testShape
+		Shapes []testShape
+		Any    any
+	}
+
+	v1 := drawing{
+		Main:   testSquare{2},
+		Shapes: []testShape{&testCircle{1}, testSquare{3}, nil},
+		Any:    testSquare{4},
+	}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v2 drawing
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %#v", v2)
+	}
+
+	// Registered types nested in maps and lists under an empty interface
+	nested := map[string]any{
+		"shape":  testSquare{5},
+		"shapes": []any{&testCircle{2}, "label"},
+	}
+	if enc, err = Marshal(nested); err != nil {
+		t.Fatal(err)
+	}
+	var generic any
+	if err := Unmarshal(enc, &generic); err != nil || !reflect.DeepEqual(generic, nested) {
+		t.Fatalf("unexpected value: %#v %v", generic, err)
+	}
+
+	// Unregistered names are an error
+	enc, err = Marshal(map[string]any{
+		"Main": map[string]any{TypeKey: "test.triangle", ValueKey: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v3 drawing
+	err = Unmarshal(enc, &v3)
+	if _, ok := err.(*UnregisteredTypeError); !ok {
+		t.Fatalf("expected UnregisteredTypeError, got %v", err)
+	}
+
+	// Unless decoding into an empty interface, which gets a plain map
+	feature := map[string]any{TypeKey: "Feature", "x": int8(1)}
+	enc, err = Marshal(feature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generic = nil
+	if err := Unmarshal(enc, &generic); err != nil || !reflect.DeepEqual(generic, feature) {
+		t.Fatalf("unexpected value: %#v %v", generic, err)
+	}
+
+	if enc, err = Marshal(map[string]any{"Any": feature}); err != nil {
+		t.Fatal(err)
+	}
+	var v4 drawing
+	if err := Unmarshal(enc, &v4); err != nil || !reflect.DeepEqual(v4.Any, feature) {
+		t.Fatalf("unexpected value: %#v %v", v4.Any, err)
+	}
+
+	err = UnmarshalOptions{RequireRegisteredTypes: true}.Unmarshal(enc, &v4)
+	if _, ok := err.(*UnregisteredTypeError); !ok {
+		t.Fatalf("expected UnregisteredTypeError, got %v", err)
+	}
+}
+
+// A type from "another module", with no marshal methods
+type testPoint struct {
+	x, y int32
+}
+
+func TestCodecs(t *testing.T) {
+	RegisterCodec(
+		func(e LtvEncoder, p testPoint) error {
+			e.WriteI32Vec([]int32{p.x, p.y})
+			return nil
+		},
+		func(d *Decoder, desc LtvDesc) (testPoint, error) {
+			v, err := d.ReadValue(desc)
+			if err != nil {
+				return testPoint{}, err
+			}
+			xy, ok := v.([]int32)
+			if !ok || len(xy) != 2 {
+				return testPoint{}, fmt.Errorf("bad point")
+			}
+			return testPoint{xy[0], xy[1]}, nil
+		})
+
+	type shape struct {
+		Origin testPoint
+		Points []testPoint
+		Ptr    *testPoint
+	}
+
+	v1 := shape{
+		Origin: testPoint{1, 2},
+		Points: []testPoint{{3, 4}, {5, 6}},
+		Ptr:    &testPoint{7, 8},
+	}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generic any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic.(map[string]any)["Origin"], []int32{1, 2}) {
+		t.Fatalf("codec not used: %#v", generic)
+	}
+
+	var v2 shape
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %#v", v2)
+	}
+
+	// Per-call codecs take precedence
+	var codecs Codecs
+	AddCodec(&codecs,
+		func(e LtvEncoder, p testPoint) error {
+			e.WriteString(fmt.Sprintf("%d,%d", p.x, p.y))
+			return nil
+		},
+		func(d *Decoder, desc LtvDesc) (p testPoint, err error) {
+			v, err := d.ReadValue(desc)
+			if err != nil {
+				return p, err
+			}
+			_, err = fmt.Sscanf(v.(string), "%d,%d", &p.x, &p.y)
+			return p, err
+		})
+
+	enc, err = MarshalOptions{Codecs: &codecs}.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generic = nil
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic.(map[string]any)["Origin"] != "1,2" {
+		t.Fatalf("codec override not used: %#v", generic)
+	}
+
+	var v3 shape
+	if err := (UnmarshalOptions{Codecs: &codecs}).Unmarshal(enc, &v3); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v3) {
+		t.Fatalf("roundtrip mismatch: %#v", v3)
+	}
+}
+
+func TestWellKnownTypes(t *testing.T) {
+	type record struct {
+		When    time.Time
+		Ancient time.Time
+		Timeout time.Duration
+		Big     *big.Int
+		NegBig  big.Int
+		Addr    netip.Addr
+		Addr6   netip.Addr
+		IP      net.IP
+	}
+
+	v1 := record{
+		When:    time.Unix(1700000000, 123456789),
+		Ancient: time.Date(1, 2, 3, 4, 5, 6, 7, time.UTC),
+		Timeout: 1500 * time.Millisecond,
+		Big:     new(big.Int).Lsh(big.NewInt(1), 100),
+		Addr:    netip.MustParseAddr("10.1.2.3"),
+		Addr6:   netip.MustParseAddr("2001:db8::1"),
+		IP:      net.ParseIP("192.168.0.1"),
+	}
+	v1.NegBig.SetInt64(-12345)
+
+	opts := MarshalOptions{WellKnownTypes: true}
+	enc, err := opts.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic["When"] != int(v1.When.UnixNano()) || generic["Timeout"] != int(v1.Timeout) {
+		t.Fatalf("unexpected time encoding: %#v", generic)
+	}
+	if !reflect.DeepEqual(generic["Addr"], []byte{10, 1, 2, 3}) || !reflect.DeepEqual(generic["IP"], []byte{192, 168, 0, 1}) {
+		t.Fatalf("unexpected address encoding: %#v", generic)
+	}
+	if _, ok := generic["NegBig"].(map[string]any); !ok {
+		t.Fatalf("unexpected big.Int encoding: %#v", generic["NegBig"])
+	}
+
+	var v2 record
+	if err := (UnmarshalOptions{WellKnownTypes: true}).Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !v2.When.Equal(v1.When) || !v2.Ancient.Equal(v1.Ancient) || v2.Timeout != v1.Timeout {
+		t.Fatalf("time mismatch: %v %v %v", v2.When, v2.Ancient, v2.Timeout)
+	}
+	if v2.Big.Cmp(v1.Big) != 0 || v2.NegBig.Cmp(&v1.NegBig) != 0 {
+		t.Fatalf("big.Int mismatch: %v %v", v2.Big, &v2.NegBig)
+	}
+
+	// Decoded values don't share storage, and nil pointers stay nil
+	v3 := v2
+	v3.Big = nil
+	enc2, err := opts.Marshal(v3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v4 := record{Big: big.NewInt(1)}
+	if err := (UnmarshalOptions{WellKnownTypes: true}).Unmarshal(enc2, &v4); err != nil {
+		t.Fatal(err)
+	}
+	v4.NegBig.Add(&v4.NegBig, big.NewInt(1))
+	if v4.Big != nil || v2.NegBig.Cmp(&v1.NegBig) != 0 {
+		t.Fatalf("big.Int aliasing: %v %v", v4.Big, &v2.NegBig)
+	}
+	if v2.Addr != v1.Addr || v2.Addr6 != v1.Addr6 || !v2.IP.Equal(v1.IP) {
+		t.Fatalf("address mismatch: %v %v %v", v2.Addr, v2.Addr6, v2.IP)
+	}
+
+	// Without the option, the text encodings are used
+	enc, err = Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generic = nil
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if generic["Addr"] != "10.1.2.3" {
+		t.Fatalf("unexpected default encoding: %#v", generic["Addr"])
+	}
+}
+
+// A type that streams itself to and from the encoder and decoder
+type testVec3 [3]float32
+
+func (v testVec3) MarshalLTVTo(e LtvEncoder) error {
+	e.WriteF32Vec(v[:])
+	return nil
+}
+
+func (v *testVec3) UnmarshalLTVFrom(d *Decoder, desc LtvDesc) error {
+	val, err := d.ReadValue(desc)
+	if err != nil {
+		return err
+	}
+	f, ok := val.([]float32)
+	if !ok || len(f) != 3 {
+		return fmt.Errorf("bad vec3")
+	}
+	copy(v[:], f)
+	return nil
+}
+
+func TestMarshalerTo(t *testing.T) {
+	type body struct {
+		Name string
+		Pos  testVec3
+		Vel  *testVec3
+	}
+
+	v1 := body{Name: "rock", Pos: testVec3{1, 2, 3}, Vel: &testVec3{-1, 0, 1}}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic["Pos"], []float32{1, 2, 3}) {
+		t.Fatalf("MarshalLTVTo not used: %#v", generic)
+	}
+
+	var v2 body
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %#v", v2)
+	}
+
+	// Stream encoding and decoding
+	var buf bytes.Buffer
+	se := NewStreamEncoder(&buf)
+	for i := 0; i < 3; i++ {
+		if err := se.Encode(v1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sd := NewStreamDecoder(&buf)
+	for i := 0; i < 3; i++ {
+		var v3 body
+		if err := sd.Decode(&v3); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v1, v3) {
+			t.Fatalf("stream roundtrip mismatch: %#v", v3)
+		}
+	}
+
+	var v4 body
+	if err := sd.Decode(&v4); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// Decode holds a whole value, containers included, to MaxValueLength
+	sd = NewStreamDecoder(bytes.NewReader(enc))
+	sd.MaxValueLength = uint64(len(enc))
+	if err := sd.Decode(&v4); err != nil || !reflect.DeepEqual(v1, v4) {
+		t.Fatalf("unexpected value: %#v %v", v4, err)
+	}
+
+	sd = NewStreamDecoder(bytes.NewReader(enc))
+	sd.MaxValueLength = uint64(len(enc) - 1)
+	if err := sd.Decode(&v4); err != errMaxValueLenExceeded {
+		t.Fatalf("expected max value length error, got %v", err)
+	}
+}
+
+func TestArrayVectors(t *testing.T) {
+	type Celsius float32
+	type record struct {
+		Pos   [3]float32
+		Hash  [4]byte
+		Flags [2]bool
+		Temps [2]Celsius
+		Names [2]string
+	}
+
+	v1 := record{
+		Pos:   [3]float32{1, 2, 3},
+		Hash:  [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Flags: [2]bool{true, false},
+		Temps: [2]Celsius{20.5, -3},
+		Names: [2]string{"a", "b"},
+	}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic["Pos"], []float32{1, 2, 3}) ||
+		!reflect.DeepEqual(generic["Hash"], []byte{0xde, 0xad, 0xbe, 0xef}) ||
+		!reflect.DeepEqual(generic["Temps"], []float32{20.5, -3}) ||
+		!reflect.DeepEqual(generic["Names"], []any{"a", "b"}) {
+		t.Fatalf("unexpected array encoding: %#v", generic)
+	}
+
+	var v2 record
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %#v", v2)
+	}
+
+	// Length mismatches are errors unless truncation is enabled
+	enc, err = Marshal([]float32{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var short [2]float32
+	if err := Unmarshal(enc, &short); err == nil {
+		t.Fatal("expected length mismatch error")
+	}
+
+	if err := (UnmarshalOptions{TruncateArrays: true}).Unmarshal(enc, &short); err != nil {
+		t.Fatal(err)
+	}
+	if short != [2]float32{1, 2} {
+		t.Fatalf("unexpected truncation: %v", short)
+	}
+
+	long := [6]float32{9, 9, 9, 9, 9, 9}
+	if err := (UnmarshalOptions{TruncateArrays: true}).Unmarshal(enc, &long); err != nil {
+		t.Fatal(err)
+	}
+	if long != [6]float32{1, 2, 3, 4, 0, 0} {
+		t.Fatalf("unexpected zero fill: %v", long)
+	}
+
+	// The list form is still accepted
+	enc, err = Marshal([]any{1.5, 2.5, 3.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pos [3]float64
+	if err := Unmarshal(enc, &pos); err != nil {
+		t.Fatal(err)
+	}
+	if pos != [3]float64{1.5, 2.5, 3.5} {
+		t.Fatalf("unexpected list decoding: %v", pos)
+	}
+}
+
+func TestComplex(t *testing.T) {
+	type capture struct {
+		Gain    complex64
+		Offset  complex128
+		Samples []complex64
+		Wide    []complex128
+		Taps    [2]complex64
+	}
+
+	v1 := capture{
+		Gain:    complex(1.5, -2),
+		Offset:  complex(0.25, 4),
+		Samples: []complex64{complex(1, 2), complex(3, 4), complex(5, 6)},
+		Wide:    []complex128{complex(-1, -2)},
+		Taps:    [2]complex64{complex(7, 8), complex(9, 10)},
+	}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic["Gain"], []float32{1.5, -2}) ||
+		!reflect.DeepEqual(generic["Samples"], []float32{1, 2, 3, 4, 5, 6}) ||
+		!reflect.DeepEqual(generic["Wide"], []float64{-1, -2}) {
+		t.Fatalf("unexpected complex encoding: %#v", generic)
+	}
+
+	var v2 capture
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %#v", v2)
+	}
+
+	// Odd length vectors can't be complex
+	enc, err = Marshal([]float32{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c []complex64
+	if err := Unmarshal(enc, &c); err == nil {
+		t.Fatal("expected error for odd length vector")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	type telemetry struct {
+		Counts  []int64
+		Sizes   []uint32
+		Ids     []int
+		Fixed   [3]int32
+		Mixed   []any
+		Signed  []any
+		Floats  []any
+		Flags   []any
+		Strings []any
+	}
+
+	v1 := telemetry{
+		Counts:  []int64{1, -2, 3},
+		Sizes:   []uint32{100, 200, 300},
+		Ids:     []int{7, 8, 9},
+		Fixed:   [3]int32{1, 2, 3},
+		Mixed:   []any{1, 2, uint8(3)},
+		Signed:  []any{1, -2, int64(300)},
+		Floats:  []any{1.5, float32(2.5)},
+		Flags:   []any{true, false},
+		Strings: []any{"a", 1},
+	}
+
+	enc, err := MarshalOptions{Compact: true}.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enc) >= len(plain) {
+		t.Fatalf("compact encoding is not smaller: %d >= %d", len(enc), len(plain))
+	}
+
+	var generic map[string]any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"Counts":  []int8{1, -2, 3},
+		"Sizes":   []uint16{100, 200, 300},
+		"Ids":     []int8{7, 8, 9},
+		"Fixed":   []int8{1, 2, 3},
+		"Mixed":   []uint8{1, 2, 3},
+		"Signed":  []int16{1, -2, 300},
+		"Floats":  []float64{1.5, 2.5},
+		"Flags":   []bool{true, false},
+		"Strings": []any{"a", int8(1)},
+	}
+	if !reflect.DeepEqual(generic, want) {
+		t.Fatalf("unexpected compact encoding: %#v", generic)
+	}
+
+	var v2 telemetry
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interface lists decode with native ints and float64s
+	v1.Mixed = []any{1, 2, 3}
+	v1.Signed = []any{1, -2, 300}
+	v1.Floats = []any{1.5, 2.5}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %#v", v2)
+	}
+}
+
+func TestFixedWidthInts(t *testing.T) {
+	type sample struct {
+		A int64
+		B uint16
+		C int
+		D int8
+		E uint32 `ltv:"e,fixed"`
+	}
+
+	v1 := sample{A: 5, B: 6, C: -7, D: 8, E: 9}
+
+	typesOf := func(enc []byte) map[string]TypeCode {
+		d := NewDecoder(enc)
+		types := make(map[string]TypeCode)
+		d.Next()
+		for {
+			desc, err := d.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if desc.TypeCode == End {
+				return types
+			}
+			key, _ := d.ReadValue(desc)
+			desc, _ = d.Next()
+			d.Skip(desc)
+			types[key.(string)] = desc.TypeCode
+		}
+	}
+
+	enc, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]TypeCode{"A": I8, "B": U8, "C": I8, "D": I8, "e": U32}
+	if got := typesOf(enc); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected types: %v", got)
+	}
+
+	enc, err = MarshalOptions{FixedWidthInts: true}.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]TypeCode{"A": I64, "B": U16, "C": I64, "D": I8, "e": U32}
+	if got := typesOf(enc); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected fixed types: %v", got)
+	}
+
+	var v2 sample
+	if err := Unmarshal(enc, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if v1 != v2 {
+		t.Fatalf("roundtrip mismatch: %v", v2)
+	}
+
+	// The tag option doesn't reach into nested values
+	type outer struct {
+		P *int32 `ltv:"p,fixed"`
+		S sample `ltv:"s,fixed"`
+		L []int  `ltv:"l,fixed"`
+	}
+	p := int32(1)
+	enc, err = Marshal(outer{P: &p, S: v1, L: []int{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generic any
+	if err := Unmarshal(enc, &generic); err != nil {
+		t.Fatal(err)
+	}
+	got := generic.(map[string]any)
+	nested := got["s"].(map[string]any)
+	if _, ok := got["p"].(int32); !ok {
+		t.Errorf("unexpected pointer field type: %T", got["p"])
+	}
+	if _, ok := nested["A"].(int8); !ok {
+		t.Errorf("unexpected nested field type: %T", nested["A"])
+	}
+	if _, ok := nested["e"].(uint32); !ok {
+		t.Errorf("unexpected nested tagged field type: %T", nested["e"])
+	}
+	if _, ok := got["l"].([]any)[0].(int8); !ok {
+		t.Errorf("unexpected element type: %T", got["l"].([]any)[0])
+	}
+}
+
+func TestNumericPolicy(t *testing.T) {
+	type tc struct {
+		in   any
+		out  any // pointer to the destination
+		opts UnmarshalOptions
+		ok   bool
+	}
+
+	var (
+		i16  int16
+		i64  int64
+		u8   uint8
+		u32  uint32
+		f32  float32
+		f64  float64
+		s32  []int32
+		su16 []uint16
+		sf32 []float32
+		si64 []int64
+		a3   [3]int64
+		c64  complex64
+		sc64 []complex64
+	)
+
+	widen := UnmarshalOptions{NumericPolicy: NumericWiden}
+	exact := UnmarshalOptions{NumericPolicy: NumericExact}
+
+	tests := []tc{
+		// Default conversions
+		{int16(300), &u32, UnmarshalOptions{}, true},
+		{int16(-1), &u32, UnmarshalOptions{}, false},
+		{float64(1.5), &f32, UnmarshalOptions{}, true},
+		{float64(2), &i64, UnmarshalOptions{}, false},
+		{[]int64{1, 2}, &su16, UnmarshalOptions{}, true},
+		{[]float32{1}, &si64, UnmarshalOptions{}, false},
+		{[]bool{true}, &si64, UnmarshalOptions{}, false},
+
+		// Widening
+		{int8(5), &i64, widen, true},
+		{uint8(5), &i16, widen, true},
+		{int8(5), &u32, widen, false},
+		{uint32(5), &u8, widen, false},
+		{float32(1), &f64, widen, true},
+		{float64(1), &f32, widen, false},
+		{float64(1), &f32, UnmarshalOptions{NumericPolicy: NumericWiden, AllowFloatNarrowing: true}, true},
+		{[]uint16{1}, &s32, widen, true},
+		{[]int64{1}, &s32, widen, false},
+		{[]int32{1, 2, 3}, &a3, widen, true},
+		{[]uint64{1, 2, 3}, &a3, widen, false},
+
+		// Exact
+		{int8(5), &i64, exact, false},
+		{int64(5), &i64, exact, true},
+		{float32(1), &f64, exact, false},
+		{[]float32{1}, &sf32, exact, true},
+		{[]float64{1}, &sf32, exact, false},
+		{[]float32{1, 2}, &sc64, exact, true},
+		{[]float64{1, 2}, &sc64, exact, false},
+		{[]float64{1, 2}, &c64, exact, false},
+		{[]float64{1, 2}, &sc64, widen, false},
+		{[]float64{1, 2}, &sc64, UnmarshalOptions{}, true},
+
+		// Integral floats
+		{float64(2), &i64, UnmarshalOptions{AllowIntegralFloat: true}, true},
+		{float64(2.5), &i64, UnmarshalOptions{AllowIntegralFloat: true}, false},
+		{float64(-1), &u8, UnmarshalOptions{AllowIntegralFloat: true}, false},
+		{float64(1e300), &i64, UnmarshalOptions{AllowIntegralFloat: true}, false},
+		{[]float64{1, 2}, &si64, UnmarshalOptions{AllowIntegralFloat: true}, true},
+		{[]float32{1, 2.5}, &si64, UnmarshalOptions{AllowIntegralFloat: true}, false},
+	}
+
+	for i, tc := range tests {
+		enc, err := MarshalOptions{FixedWidthInts: true}.Marshal(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = tc.opts.Unmarshal(enc, tc.out)
+		if tc.ok && err != nil {
+			t.Errorf("%d: %T into %T: %v", i, tc.in, tc.out, err)
+		}
+		if !tc.ok {
+			if _, isType := err.(*UnmarshalTypeError); !isType {
+				t.Errorf("%d: %T into %T: expected a type error, got %v", i, tc.in, tc.out, err)
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(a3, [3]int64{1, 2, 3}) || !reflect.DeepEqual(si64, []int64{1, 2}) || i64 != 2 {
+		t.Errorf("unexpected values: %v %v %v", a3, si64, i64)
+	}
+
+	// The field path is reported
+	type inner struct{ N int32 }
+	type outer struct{ In inner }
+	enc, err := Marshal(struct{ In struct{ N int } }{In: struct{ N int }{5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o outer
+	err = UnmarshalOptions{NumericPolicy: NumericExact}.Unmarshal(enc, &o)
+	if ute, ok := err.(*UnmarshalTypeError); !ok || ute.Field != "In.N" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFieldMatching(t *testing.T) {
+	type inner struct {
+		Name string
+		N    int `ltv:"n"`
+	}
+	type outer struct {
+		In inner
+		M  map[string]int
+	}
+
+	encodeStruct := func(kv ...any) *Encoder {
+		e := NewEncoder()
+		e.WriteStructStart()
+		for i := 0; i < len(kv); i += 2 {
+			e.WriteString(kv[i].(string))
+			switch v := kv[i+1].(type) {
+			case string:
+				e.WriteString(v)
+			case int:
+				e.WriteInt(int64(v))
+			}
+		}
+		e.WriteStructEnd()
+		return e
+	}
+
+	// Wrap an inner struct in {"In": inner}
+	nested := func(kv ...any) []byte {
+		e := NewEncoder()
+		e.WriteStructStart()
+		e.WriteString("In")
+		e.RawWrite(encodeStruct(kv...).Bytes())
+		e.WriteStructEnd()
+		return e.Bytes()
+	}
+
+	var fe *FieldError
+
+	// Unknown fields, with the known fields still decoded
+	var o outer
+	err := Unmarshal(nested("Name", "a", "Other", 1), &o)
+	if !errors.Is(err, ErrUnknownField) || !errors.As(err, &fe) || fe.Key != "Other" || fe.Field != "In" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.In.Name != "a" {
+		t.Fatalf("known fields not decoded: %v", o)
+	}
+
+	// Case-insensitive fallback
+	o = outer{}
+	if err := Unmarshal(nested("NAME", "b", "N", 2), &o); err != nil || o.In != (inner{"b", 2}) {
+		t.Fatalf("unexpected result: %v %v", o, err)
+	}
+
+	// Case-sensitive matching treats the keys as unknown
+	o = outer{}
+	err = UnmarshalOptions{CaseSensitive: true}.Unmarshal(nested("NAME", "b", "N", 2), &o)
+	if !errors.Is(err, ErrUnknownField) || o.In != (inner{}) {
+		t.Fatalf("unexpected result: %v %v", o, err)
+	}
+
+	// Duplicates, including case-insensitive matches of the same field
+	o = outer{}
+	err = UnmarshalOptions{DisallowDuplicateKeys: true}.Unmarshal(nested("Name", "d", "name", "e"), &o)
+	if !errors.Is(err, ErrDuplicateKey) || !errors.As(err, &fe) || fe.Key != "name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.In.Name != "d" {
+		t.Fatalf("duplicate overwrote the first value: %v", o)
+	}
+
+	// The last duplicate wins by default
+	o = outer{}
+	if err := Unmarshal(nested("Name", "d", "name", "e"), &o); err != nil || o.In.Name != "e" {
+		t.Fatalf("unexpected result: %v %v", o, err)
+	}
+
+	// Duplicate map keys
+	var m map[string]int
+	enc := encodeStruct("a", 1, "b", 2, "a", 3).Bytes()
+	if err := Unmarshal(enc, &m); err != nil || m["a"] != 3 {
+		t.Fatalf("unexpected result: %v %v", m, err)
+	}
+	m = nil
+	err = UnmarshalOptions{DisallowDuplicateKeys: true}.Unmarshal(enc, &m)
+	if !errors.Is(err, ErrDuplicateKey) || m["a"] != 1 || m["b"] != 2 {
+		t.Fatalf("unexpected result: %v %v", m, err)
+	}
+}
+
+func TestRemain(t *testing.T) {
+	type v2 struct {
+		Name  string
+		Count int
+		Tags  []string
+		Score float32
+	}
+	type v1Any struct {
+		Name  string
+		Extra map[string]any `ltv:",remain"`
+	}
+	type v1Raw struct {
+		Name  string
+		Extra map[string][]byte `ltv:",inline"`
+	}
+
+	in := v2{Name: "a", Count: 300, Tags: []string{"x", "y"}, Score: 1.5}
+	enc, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generic values
+	var a v1Any
+	if err := Unmarshal(enc, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "a" || len(a.Extra) != 3 || a.Extra["Count"] != 300 {
+		t.Fatalf("unexpected value: %#v", a)
+	}
+
+	b, err := Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out v2
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("roundtrip mismatch: %v", out)
+	}
+
+	// Raw values are written back
+	var r v1Raw
+	if err := Unmarshal(enc, &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Extra) != 3 {
+		t.Fatalf("unexpected value: %#v", r)
+	}
+
+	b, err = Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = v2{}
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("roundtrip mismatch: %v", out)
+	}
+
+	// Keys of known fields aren't repeated
+	r.Extra["Name"] = []byte{0x40, 'z'}
+	b, err = Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (UnmarshalOptions{DisallowDuplicateKeys: true}).Unmarshal(b, &out); err != nil || out.Name != "a" {
+		t.Fatalf("unexpected result: %v %v", out, err)
+	}
+
+	// Raw vectors are aligned for their new position
+	enc, err = Marshal(struct {
+		Name string
+		Vec  []float64
+	}{"a", []float64{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = v1Raw{}
+	if err := Unmarshal(enc, &r); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "bc", "defg"} {
+		r.Name = name
+		b, err = Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewDecoder(b)
+		for {
+			desc, err := d.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if desc.TypeCode == F64 && d.pos%8 != 0 {
+				t.Fatalf("unaligned vector at %d: % x", d.pos, b)
+			}
+			if desc.TypeCode > End {
+				d.Skip(desc)
+			}
+		}
+	}
+
+	// Invalid raw values
+	r.Extra["bad"] = []byte{0x10}
+	if _, err := Marshal(r); err == nil {
+		t.Fatal("expected an error for an invalid raw value")
+	}
+
+	// Duplicate unrecognized keys
+	e := NewEncoder()
+	e.WriteStructStart()
+	e.WriteString("k")
+	e.WriteInt(1)
+	e.WriteString("k")
+	e.WriteInt(2)
+	e.WriteStructEnd()
+	err = UnmarshalOptions{DisallowDuplicateKeys: true}.Unmarshal(e.Bytes(), &a)
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRequiredAndDefaults(t *testing.T) {
+	type limits struct {
+		Max   *int `ltv:"max,default=10"`
+		Extra string
+	}
+	type config struct {
+		Host    string        `ltv:"host,required"`
+		Port    int           `ltv:"port,default=8080"`
+		Ratio   float32       `ltv:"ratio,default=0.5"`
+		Debug   bool          `ltv:"debug,default=true"`
+		Timeout time.Duration `ltv:"timeout,default=1m30s"`
+		Addr    netip.Addr    `ltv:"addr,default=10.0.0.1"`
+		Mask    uint8         `ltv:"mask,default=0xff"`
+		Limits  limits
+	}
+
+	enc, err := Marshal(map[string]any{
+		"host":   "example",
+		"debug":  false,
+		"Limits": map[string]any{"Extra": "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c config
+	if err := Unmarshal(enc, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	ten := 10
+	want := config{
+		Host:    "example",
+		Port:    8080,
+		Ratio:   0.5,
+		Debug:   false,
+		Timeout: 90 * time.Second,
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Mask:    0xff,
+		Limits:  limits{Max: &ten, Extra: "x"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Fatalf("unexpected value: %+v", c)
+	}
+
+	// Present keys are kept, including zero values
+	enc, err = Marshal(config{Host: "h", Port: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = config{}
+	if err := Unmarshal(enc, &c); err != nil || c.Port != 0 || c.Limits.Max != nil {
+		t.Fatalf("unexpected result: %+v %v", c, err)
+	}
+
+	// Missing required keys
+	type outer struct {
+		Conf config
+	}
+	enc, err = Marshal(map[string]any{"Conf": map[string]any{"port": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o outer
+	err = Unmarshal(enc, &o)
+	var fe *FieldError
+	if !errors.Is(err, ErrMissingField) || !errors.As(err, &fe) || fe.Key != "host" || fe.Field != "Conf" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.Conf.Port != 1 || o.Conf.Ratio != 0.5 {
+		t.Fatalf("other fields not decoded: %+v", o)
+	}
+
+	// Defaults which refer to memory aren't shared between decodes
+	type shared struct {
+		IP  net.IP   `ltv:"ip,default=10.0.0.1"`
+		Big *big.Int `ltv:"big,default=12345678901234567890"`
+	}
+	empty, _ := Marshal(map[string]any{})
+	var s1, s2 shared
+	if err := Unmarshal(empty, &s1); err != nil {
+		t.Fatal(err)
+	}
+	s1.IP[3] = 99
+	s1.Big.SetInt64(1)
+	if err := Unmarshal(empty, &s2); err != nil {
+		t.Fatal(err)
+	}
+	if !s2.IP.Equal(net.ParseIP("10.0.0.1")) || s2.Big.String() != "12345678901234567890" {
+		t.Fatalf("default changed by a previous decode: %v %v", s2.IP, s2.Big)
+	}
+
+	// Invalid defaults
+	type bad struct {
+		N int8 `ltv:"n,default=300"`
+	}
+	var b bad
+	if err := Unmarshal(enc, &b); err == nil {
+		t.Fatal("expected an error for an invalid default")
+	}
+
+	// The invalid default is reported even when the key is present
+	enc, err = Marshal(map[string]any{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(enc, &b); err == nil {
+		t.Fatal("expected an error for an invalid default with the key present")
+	}
+}
+
+type testZeroer struct {
+	N int
+}
+
+// Zero when N is negative
+func (z testZeroer) IsZero() bool {
+	return z.N < 0
+}
+
+type testPtrZeroer struct {
+	S string
+}
+
+func (z *testPtrZeroer) IsZero() bool {
+	return z.S == "zero"
+}
+
+func TestOmitZero(t *testing.T) {
+	type inner struct {
+		A int
+		B string
+	}
+	type sample struct {
+		Time   time.Time     `ltv:",omitzero"`
+		Inner  inner         `ltv:",omitzero"`
+		Arr    [2]int        `ltv:",omitzero"`
+		Ptr    *inner        `ltv:",omitzero"`
+		Slice  []int         `ltv:",omitzero"`
+		Val    testZeroer    `ltv:",omitzero"`
+		PtrVal testPtrZeroer `ltv:",omitzero"`
+		Iface  isZeroer      `ltv:",omitzero"`
+		Kept   inner         `ltv:",omitempty"`
+	}
+
+	keys := func(v any) []string {
+		enc, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]any
+		if err := Unmarshal(enc, &m); err != nil {
+			t.Fatal(err)
+		}
+		var k []string
+		for key := range m {
+			k = append(k, key)
+		}
+		sort.Strings(k)
+		return k
+	}
+
+	// testZeroer{0} isn't zero by its own definition
+	zero := sample{Val: testZeroer{-1}, PtrVal: testPtrZeroer{"zero"}, Iface: (*testPtrZeroer)(nil)}
+	if got := keys(zero); !reflect.DeepEqual(got, []string{"Kept"}) {
+		t.Errorf("unexpected keys: %v", got)
+	}
+	if got := keys(&zero); !reflect.DeepEqual(got, []string{"Kept"}) {
+		t.Errorf("unexpected keys: %v", got)
+	}
+
+	full := sample{
+		Time:   time.Unix(1, 0),
+		Inner:  inner{B: "b"},
+		Arr:    [2]int{0, 1},
+		Ptr:    &inner{},
+		Slice:  []int{},
+		PtrVal: testPtrZeroer{"x"},
+		Iface:  testZeroer{1},
+	}
+	want := []string{"Arr", "Iface", "Inner", "Kept", "Ptr", "PtrVal", "Slice", "Time", "Val"}
+	if got := keys(full); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected keys: %v", got)
+	}
+
+	// Checks on addressable fields, as of a struct marshaled through a
+	// pointer, don't allocate
+	type noAlloc struct {
+		Time   time.Time     `ltv:",omitzero"`
+		Inner  inner         `ltv:",omitzero"`
+		Arr    [2]int        `ltv:",omitzero"`
+		Val    testZeroer    `ltv:",omitzero"`
+		PtrVal testPtrZeroer `ltv:",omitzero"`
+	}
+	buf := make([]byte, 0, 256)
+	for _, v := range []*noAlloc{{}, {Val: testZeroer{1}, PtrVal: testPtrZeroer{"x"}}} {
+		if n := testing.AllocsPerRun(100, func() { MarshalAppend(buf, v) }); n != 0 {
+			t.Errorf("%+v: %v allocations", *v, n)
+		}
+	}
+}
+
+type testNode struct {
+	Name     string
+	Children []*testNode
+	Parent   *testNode
+	Data     *[2]int
+}
+
+func TestReferences(t *testing.T) {
+	data := &[2]int{1, 2}
+	root := &testNode{Name: "root"}
+	shared := &testNode{Name: "shared", Data: data}
+	a := &testNode{Name: "a", Parent: root, Children: []*testNode{shared}, Data: data}
+	b := &testNode{Name: "b", Parent: root, Children: []*testNode{shared, shared}}
+	root.Children = []*testNode{a, b}
+
+	// Cycles fail without references
+	if _, err := Marshal(root); err == nil {
+		t.Fatal("expected a cycle error")
+	}
+
+	enc, err := MarshalOptions{References: true}.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out *testNode
+	if err := (UnmarshalOptions{References: true}).Unmarshal(enc, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Name != "root" || len(out.Children) != 2 {
+		t.Fatalf("unexpected root: %+v", out)
+	}
+	oa, ob := out.Children[0], out.Children[1]
+	if oa.Parent != out || ob.Parent != out {
+		t.Error("parent pointers not shared")
+	}
+	if oa.Children[0] != ob.Children[0] || ob.Children[0] != ob.Children[1] || oa.Children[0].Name != "shared" {
+		t.Error("shared node not shared")
+	}
+	if oa.Data != oa.Children[0].Data || *oa.Data != *data {
+		t.Error("shared array not shared")
+	}
+	if ob.Data != nil {
+		t.Error("unexpected data")
+	}
+
+	// Unshared pointers are written as usual
+	single := &testNode{Name: "x", Data: &[2]int{3, 4}}
+	enc1, err := Marshal(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc2, err := MarshalOptions{References: true}.Marshal(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc1, enc2) {
+		t.Error("unshared pointers written with ids")
+	}
+
+	// Through a stream
+	var buf bytes.Buffer
+	se := NewStreamEncoder(&buf)
+	se.Options.References = true
+	if err := se.Encode(root); err != nil {
+		t.Fatal(err)
+	}
+	sd := NewStreamDecoder(&buf)
+	sd.Options.References = true
+	out = nil
+	if err := sd.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Children[0].Parent != out {
+		t.Error("parent pointers not shared")
+	}
+
+	// Unknown references
+	e := NewEncoder()
+	e.WriteStructStart()
+	e.WriteString("Parent")
+	e.WriteStructStart()
+	e.WriteString(RefKey)
+	e.WriteU32(7)
+	e.WriteStructEnd()
+	e.WriteStructEnd()
+	out = nil
+	if err := (UnmarshalOptions{References: true}).Unmarshal(e.Bytes(), &out); err == nil {
+		t.Error("expected an unknown reference error")
+	}
+}
+
+func TestSequences(t *testing.T) {
+	type result struct {
+		Rows  chan int
+		Names func(yield func(string) bool)
+		None  chan int
+	}
+
+	rows := make(chan int, 3)
+	rows <- 1
+	rows <- 2
+	rows <- 300
+	close(rows)
+
+	names := func(yield func(string) bool) {
+		for _, n := range []string{"a", "b", "c"} {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+
+	enc, err := Marshal(result{Rows: rows, Names: names})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Rows  []int
+		Names []string
+		None  []int
+	}
+	if err := Unmarshal(enc, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Rows, []int{1, 2, 300}) || !reflect.DeepEqual(out.Names, []string{"a", "b", "c"}) || out.None != nil {
+		t.Fatalf("unexpected value: %+v", out)
+	}
+
+	// Iterator errors stop the encoding
+	errCursor := errors.New("cursor failed")
+	cursor := func(yield func(int, error) bool) {
+		for i := 0; i < 5; i++ {
+			if i == 3 {
+				yield(0, errCursor)
+				return
+			}
+			if !yield(i, nil) {
+				return
+			}
+		}
+	}
+	if _, err := Marshal(cursor); !errors.Is(err, errCursor) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Encoding errors stop the iterator through yield, rather than
+	// unwinding through it
+	stopped := false
+	unsupported := func(yield func(any) bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("iterator unwound by %v", r)
+			}
+		}()
+		if !yield(1) || yield(func() {}) {
+			t.Error("yield didn't return false after an encoding error")
+		}
+		stopped = true
+	}
+	var ute *UnsupportedTypeError
+	if _, err := Marshal(unsupported); !errors.As(err, &ute) || !stopped {
+		t.Fatalf("unexpected result: %v %v", err, stopped)
+	}
+
+	// Elements are written as they are produced
+	var buf bytes.Buffer
+	se := NewStreamEncoder(&buf)
+	counter := func(yield func(int) bool) {
+		for i := 0; i < 3; i++ {
+			if i > 0 && buf.Len() == 0 {
+				t.Error("element not written before the next was produced")
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	if err := se.Encode(counter); err != nil {
+		t.Fatal(err)
+	}
+	var ints []int
+	if err := Unmarshal(buf.Bytes(), &ints); err != nil || !reflect.DeepEqual(ints, []int{0, 1, 2}) {
+		t.Fatalf("unexpected result: %v %v", ints, err)
+	}
+
+	// Cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	endless := make(chan int)
+	go func() {
+		for i := 0; ; i++ {
+			if i == 10 {
+				cancel()
+			}
+			select {
+			case endless <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	if err := NewStreamEncoder(io.Discard).EncodeContext(ctx, endless); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	if err := NewStreamEncoder(io.Discard).EncodeContext(ctx2, counter); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Sequences can't be traversed twice
+	if _, err := (MarshalOptions{References: true}).Marshal(names); err == nil {
+		t.Fatal("expected an error with references")
+	}
+
+	// Send-only channels and other functions are unsupported
+	if _, err := Marshal(make(chan<- int)); err == nil {
+		t.Fatal("expected an error for a send-only channel")
+	}
+	if _, err := Marshal(func() {}); err == nil {
+		t.Fatal("expected an error for a function")
+	}
+}
+
+func TestListReader(t *testing.T) {
+	type record struct {
+		ID   int
+		Name string
+		Vals []float32
+	}
+
+	var buf bytes.Buffer
+	se := NewStreamEncoder(&buf)
+	se.WriteListStart()
+	for i := 0; i < 100; i++ {
+		if err := se.Encode(record{ID: i, Name: fmt.Sprint("r", i), Vals: []float32{float32(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	se.WriteListEnd()
+	se.WriteString("after")
+	data := buf.Bytes()
+
+	sd := NewStreamDecoder(bytes.NewReader(data))
+	r := NewListReader[record](sd)
+	n := 0
+	for r.Next() {
+		v := r.Value()
+		if v.ID != n || v.Name != fmt.Sprint("r", n) || v.Vals[0] != float32(n) {
+			t.Fatalf("unexpected record: %+v", v)
+		}
+		n++
+	}
+	if r.Err() != nil || n != 100 {
+		t.Fatalf("read %d records: %v", n, r.Err())
+	}
+
+	// The decoder is left after the list
+	if v, err := sd.Value(); err != nil || v != "after" {
+		t.Fatalf("unexpected value after list: %v %v", v, err)
+	}
+
+	// Truncated lists
+	r = NewListReader[record](NewStreamDecoder(bytes.NewReader(data[:len(data)/2])))
+	for r.Next() {
+	}
+	if !errors.Is(r.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error: %v", r.Err())
+	}
+
+	// Not a list
+	r = NewListReader[record](NewStreamDecoder(bytes.NewReader(data[len(data)-7:])))
+	if r.Next() || r.Err() == nil {
+		t.Fatal("expected an error for a non-list value")
+	}
+}
+
+func TestStreamTokens(t *testing.T) {
+	enc, err := Marshal(map[string]any{"a": []any{1, "x"}, "b": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tokens []any
+	sd := NewStreamDecoder(bytes.NewReader(enc))
+	for {
+		tok, err := sd.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tok)
+	}
+
+	want := []any{Delim('{'), "a", Delim('['), int8(1), "x", Delim(']'), "b", nil, Delim('}')}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+
+	// Tokens mixed with Decode and More
+	enc, err = Marshal(map[string]any{"items": []int{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd = NewStreamDecoder(bytes.NewReader(enc))
+	for _, want := range []any{Delim('{'), "items", Delim('[')} {
+		if tok, err := sd.Token(); err != nil || tok != want {
+			t.Fatalf("unexpected token: %v %v", tok, err)
+		}
+	}
+
+	var items []int
+	for sd.More() {
+		var n int
+		if err := sd.Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, n)
+	}
+	if !reflect.DeepEqual(items, []int{1, 2, 3}) {
+		t.Fatalf("unexpected items: %v", items)
+	}
+	for _, want := range []any{Delim(']'), Delim('}')} {
+		if tok, err := sd.Token(); err != nil || tok != want {
+			t.Fatalf("unexpected token: %v %v", tok, err)
+		}
+	}
+	if sd.More() {
+		t.Fatal("unexpected values at the end of the stream")
+	}
+}
+
+// Counts calls to its marshaler
+type testCounted struct {
+	calls *int
+}
+
+func (c testCounted) MarshalLTVTo(e LtvEncoder) error {
+	*c.calls++
+	e.WriteU8(1)
+	return nil
+}
+
+func TestSize(t *testing.T) {
+	type frame struct {
+		Seq     uint8
+		Label   string
+		Samples []int16
+		Gains   []float64
+		Flags   []bool
+		Tags    map[string]int
+		When    time.Time
+		Next    *frame
+	}
+
+	shared := &testNode{Name: "shared"}
+	values := []any{
+		nil,
+		"x",
+		int64(1 << 40),
+		[]any{uint8(1), []float64{1, 2}, "abc", []float32{3}},
+		frame{
+			Seq:     7,
+			Label:   "misaligned",
+			Samples: []int16{1, -2, 3},
+			Gains:   []float64{0.5, 0.25},
+			Flags:   []bool{true},
+			Tags:    map[string]int{"a": 1, "b": 300},
+			When:    time.Unix(1700000000, 5),
+			Next:    &frame{Seq: 8, Samples: make([]int16, 300)},
+		},
+		[]*testNode{shared, shared},
+		[]int{1, 2, 1 << 20},
+	}
+
+	for _, opts := range []MarshalOptions{{}, {Compact: true}, {WellKnownTypes: true}, {References: true}, {FixedWidthInts: true}} {
+		for _, v := range values {
+			b, err := opts.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := opts.Size(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(b) || cap(b) != len(b) {
+				t.Errorf("%+v: size of %T is %d, encoded %d (cap %d)", opts, v, n, len(b), cap(b))
+			}
+
+			// Encoding into an exactly sized buffer
+			e := NewFixedEncoder(make([]byte, 0, n))
+			if err := opts.MarshalTo(e, v); err != nil || !bytes.Equal(e.Bytes(), b) {
+				t.Errorf("%+v: fixed encoding of %T: % x %v", opts, v, e.Bytes(), err)
+			}
+		}
+	}
+
+	// Counting from an offset matches an Encoder with data before it
+	e := NewEncoder()
+	e.WriteU8(1)
+	e.WriteF64Vec([]float64{1, 2, 3})
+	e.WriteString("abc")
+	e.WriteI32Vec([]int32{4})
+	var c CountingEncoder
+	c.SetOffset(2)
+	c.WriteF64Vec([]float64{1, 2, 3})
+	c.WriteString("abc")
+	c.WriteI32Vec([]int32{4})
+	if c.Len() != e.Len() {
+		t.Errorf("counted %d, encoded %d", c.Len(), e.Len())
+	}
+
+	// Sequences can't be sized, but can still be marshaled
+	ch := make(chan int, 2)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	if _, err := Size(ch); err == nil {
+		t.Error("expected an error sizing a channel")
+	}
+	var got []int
+	if b, err := Marshal(ch); err != nil {
+		t.Fatal(err)
+	} else if err := Unmarshal(b, &got); err != nil || !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("unexpected value: %v %v", got, err)
+	}
+
+	// Marshal doesn't size ahead, so marshalers are called once
+	calls := 0
+	for _, opts := range []MarshalOptions{{}, {References: true}} {
+		calls = 0
+		if _, err := opts.Marshal([]testCounted{{&calls}}); err != nil || calls != 1 {
+			t.Errorf("%+v: marshaler called %d times: %v", opts, calls, err)
+		}
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	type sample struct {
+		ID     uint16
+		Name   string
+		Values []float32
+	}
+	v := &sample{ID: 7, Name: "probe", Values: []float32{1, 2, 3}}
+
+	want, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := MarshalAppend(nil, v)
+	if err != nil || !bytes.Equal(buf, want) {
+		t.Fatalf("unexpected encoding: %x %v", buf, err)
+	}
+
+	// Messages appended to one buffer decode in turn
+	buf, err = MarshalAppend(buf, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewStreamDecoder(bytes.NewReader(buf))
+	for i := 0; i < 2; i++ {
+		var got sample
+		if err := d.Decode(&got); err != nil || !reflect.DeepEqual(&got, v) {
+			t.Fatalf("message %d: %+v %v", i, got, err)
+		}
+	}
+
+	// Errors leave dst as it was
+	prefix := []byte{1, 2, 3}
+	if out, err := MarshalAppend(prefix, func() {}); err == nil || !bytes.Equal(out, prefix) {
+		t.Fatalf("unexpected result: %x %v", out, err)
+	}
+
+	// After a panic, later encodings don't write into dst
+	dst := append(make([]byte, 0, 64), prefix...)
+	orig := bytes.Clone(dst[:cap(dst)])
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected a panic for invalid UTF-8")
+			}
+		}()
+		MarshalAppend(dst, "bad\xff")
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := Marshal("ok"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(dst[:cap(dst)], orig) {
+		t.Fatalf("dst was written after a panic: %x", dst[:cap(dst)])
+	}
+
+	// Reusing a buffer doesn't allocate
+	buf = make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, err = MarshalAppend(buf[:0], v)
+	})
+	if err != nil || allocs != 0 {
+		t.Errorf("MarshalAppend allocated %v times: %v", allocs, err)
+	}
+
+	e := NewEncoder()
+	if err := MarshalTo(e, v); err != nil || !bytes.Equal(e.Bytes(), want) {
+		t.Fatalf("unexpected encoding: %x %v", e.Bytes(), err)
+	}
+
+	// A partial encoding is rolled back
+	e = NewFixedEncoder(make([]byte, 0, 16))
+	e.WriteU8(1)
+	err = MarshalTo(e, v)
+	var sbe *ShortBufferError
+	if !errors.As(err, &sbe) || sbe.Needed < len(want) || e.Len() != 2 || e.Err() != nil {
+		t.Fatalf("unexpected result: %v, %d bytes", err, e.Len())
+	}
+	if err := MarshalTo(e, "ok"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Vectors of named element types
+	type level int16
+	var levels []int16
+	if buf, err := MarshalAppend(nil, []level{-1, 1}); err != nil {
+		t.Fatal(err)
+	} else if err := Unmarshal(buf, &levels); err != nil || !reflect.DeepEqual(levels, []int16{-1, 1}) {
+		t.Fatalf("unexpected value: %v %v", levels, err)
+	}
+}
+
+// Named numbers with their own encodings, which vectors must not bypass
+type testLevel int16
+
+func (l testLevel) MarshalLTV() ([]byte, error) {
+	e := NewEncoder()
+	e.WriteString(fmt.Sprintf("L%d", l))
+	return e.Bytes(), nil
+}
+
+type testCode uint32
+
+type testPlain uint16
+
+func TestVectorElemEncodings(t *testing.T) {
+	RegisterCodec(
+		func(e LtvEncoder, c testCode) error {
+			e.WriteString(fmt.Sprintf("C%d", c))
+			return nil
+		},
+		func(d *Decoder, desc LtvDesc) (c testCode, err error) {
+			v, err := d.ReadValue(desc)
+			if err != nil {
+				return c, err
+			}
+			_, err = fmt.Sscanf(v.(string), "C%d", &c)
+			return c, err
+		})
+
+	var plainCodecs Codecs
+	AddCodec(&plainCodecs,
+		func(e LtvEncoder, p testPlain) error {
+			e.WriteString(fmt.Sprintf("P%d", p))
+			return nil
+		},
+		func(d *Decoder, desc LtvDesc) (p testPlain, err error) {
+			err = d.Skip(desc)
+			return p, err
+		})
+
+	tests := []struct {
+		opts MarshalOptions
+		v    any
+		want any
+	}{
+		{MarshalOptions{}, []testLevel{1, 2}, []any{"L1", "L2"}},
+		{MarshalOptions{Compact: true}, []testLevel{1, 2}, []any{"L1", "L2"}},
+		{MarshalOptions{}, []testCode{7}, []any{"C7"}},
+		{MarshalOptions{}, []testPlain{3}, []uint16{3}},
+		{MarshalOptions{Codecs: &plainCodecs}, []testPlain{3}, []any{"P3"}},
+		{MarshalOptions{}, [2]testLevel{1, 2}, []any{"L1", "L2"}},
+		{MarshalOptions{Compact: true}, [2]testLevel{1, 2}, []any{"L1", "L2"}},
+		{MarshalOptions{}, [1]testCode{7}, []any{"C7"}},
+		{MarshalOptions{}, [1]testPlain{3}, []uint16{3}},
+		{MarshalOptions{Codecs: &plainCodecs}, [1]testPlain{3}, []any{"P3"}},
+	}
+	for _, tt := range tests {
+		b, err := tt.opts.Marshal(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got any
+		if err := Unmarshal(b, &got); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v %T: got %#v, want %#v (%v)", tt.opts, tt.v, got, tt.want, err)
+		}
+	}
+
+	// Well known types use their codecs
+	opts := MarshalOptions{WellKnownTypes: true}
+	b1, err1 := opts.Marshal([]time.Duration{time.Second})
+	b2, err2 := opts.Marshal([]any{time.Second})
+	if err1 != nil || err2 != nil || !bytes.Equal(b1, b2) {
+		t.Fatalf("unexpected encoding: % x, want % x (%v %v)", b1, b2, err1, err2)
+	}
+
+	// Named element types of the same kind as the vector, as
+	// written and converted from other vector types
+	type celsius float32
+	type flag bool
+	type named struct {
+		Temps  []celsius
+		Wide   []celsius
+		Flags  []flag
+		Levels []testPlain
+		Pair   [2]celsius
+	}
+	v1 := named{
+		Temps:  []celsius{1.5, -2},
+		Wide:   []celsius{3},
+		Flags:  []flag{true, false},
+		Levels: []testPlain{1, 300},
+		Pair:   [2]celsius{4, 5},
+	}
+	b, err := Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v2 named
+	if err := Unmarshal(b, &v2); err != nil || !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch: %+v %v", v2, err)
+	}
+	b, err = Marshal(map[string]any{"Wide": []float64{3}, "Pair": []float64{4, 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v3 named
+	if err := Unmarshal(b, &v3); err != nil || !reflect.DeepEqual(v3.Wide, v1.Wide) || v3.Pair != v1.Pair {
+		t.Fatalf("unexpected value: %+v %v", v3, err)
+	}
+
+	// Arrays are written as the slices of the same elements
+	pairs := [][2]any{
+		{[]int{1, 2, 300}, [3]int{1, 2, 300}},
+		{[]uint{1, 2, 300}, [3]uint{1, 2, 300}},
+		{[]int32{1, -2}, [2]int32{1, -2}},
+		{[]testLevel{1}, [1]testLevel{1}},
+	}
+	for _, opts := range []MarshalOptions{{}, {Compact: true}, {FixedWidthInts: true}} {
+		for _, p := range pairs {
+			b1, err1 := opts.Marshal(p[0])
+			b2, err2 := opts.Marshal(p[1])
+			if err1 != nil || err2 != nil || !bytes.Equal(b1, b2) {
+				t.Errorf("%+v %T: % x, slice % x (%v %v)", opts, p[1], b2, b1, err1, err2)
+			}
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"unicode/utf8"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+type ltv2mp struct {
+	s       *ltv.StreamDecoder
+	scratch [9]byte
+}
+
+// Streaming LiteVector to MessagePack transcoder.
+//
+// MessagePack arrays and maps are prefixed with their element count, so each
+// top level LiteVector value is transcoded in memory before being written.
+// Integers are written in their most compact MessagePack form.
+func Ltv2Msgpack(r io.Reader, w io.Writer) error {
+
+	t := ltv2mp{s: ltv.NewStreamDecoder(r)}
+	bw := bufio.NewWriter(w)
+	var buf bytes.Buffer
+
+	for {
+		d, err := t.s.Next()
+		if err == io.EOF {
+			return bw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		buf.Reset()
+		if err := t.value(&buf, d); err != nil {
+			return err
+		}
+
+		if _, err := bw.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// Transcode the value described by d.
+func (t *ltv2mp) value(w *bytes.Buffer, d ltv.LtvElementDesc) error {
+
+	switch d.TypeCode {
+	case ltv.Nil:
+		w.WriteByte(mpNil)
+		return nil
+	case ltv.Struct:
+		return t.structure(w)
+	case ltv.List:
+		return t.list(w)
+	case ltv.String:
+		buf, err := t.readBytes(d)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(buf) {
+			return errBadUtf8
+		}
+		t.writeStrHeader(w, len(buf))
+		w.Write(buf)
+		return nil
+	}
+
+	if d.SizeCode == ltv.SizeSingle {
+		return t.scalar(w, d.TypeCode)
+	}
+
+	// U8 vectors are binary data, other vectors are arrays
+	if d.TypeCode == ltv.U8 {
+		buf, err := t.readBytes(d)
+		if err != nil {
+			return err
+		}
+		t.writeBinHeader(w, len(buf))
+		w.Write(buf)
+		return nil
+	}
+
+	count := d.Length / uint64(d.TypeCode.Size())
+	if count > math.MaxUint32 {
+		return errTooLong
+	}
+	t.writeArrayHeader(w, int(count))
+	for i := uint64(0); i < count; i++ {
+		if err := t.scalar(w, d.TypeCode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read the full value of a string or vector
+func (t *ltv2mp) readBytes(d ltv.LtvElementDesc) ([]byte, error) {
+	if d.Length > math.MaxUint32 {
+		return nil, errTooLong
+	}
+	return readLen(t.s, int(d.Length))
+}
+
+// Read a single scalar of type c from the stream and transcode it.
+func (t *ltv2mp) scalar(w *bytes.Buffer, c ltv.TypeCode) error {
+	buf := t.scratch[:c.Size()]
+	if err := t.s.ReadFull(buf); err != nil {
+		return err
+	}
+
+	switch c {
+	case ltv.Bool:
+		if buf[0] != 0 {
+			w.WriteByte(mpTrue)
+		} else {
+			w.WriteByte(mpFalse)
+		}
+	case ltv.U8:
+		t.writeUint(w, uint64(buf[0]))
+	case ltv.U16:
+		t.writeUint(w, uint64(binary.LittleEndian.Uint16(buf)))
+	case ltv.U32:
+		t.writeUint(w, uint64(binary.LittleEndian.Uint32(buf)))
+	case ltv.U64:
+		t.writeUint(w, binary.LittleEndian.Uint64(buf))
+	case ltv.I8:
+		t.writeInt(w, int64(int8(buf[0])))
+	case ltv.I16:
+		t.writeInt(w, int64(int16(binary.LittleEndian.Uint16(buf))))
+	case ltv.I32:
+		t.writeInt(w, int64(int32(binary.LittleEndian.Uint32(buf))))
+	case ltv.I64:
+		t.writeInt(w, int64(binary.LittleEndian.Uint64(buf)))
+	case ltv.F32:
+		w.WriteByte(mpFloat32)
+		w.Write(buf)
+		reverse(w.Bytes()[w.Len()-4:])
+	case ltv.F64:
+		w.WriteByte(mpFloat64)
+		w.Write(buf)
+		reverse(w.Bytes()[w.Len()-8:])
+	}
+
+	return nil
+}
+
+// Byte swap in place (little endian <-> big endian)
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// Transcode the remainder of a list as an array.
+func (t *ltv2mp) list(w *bytes.Buffer) error {
+	var body bytes.Buffer
+	count := 0
+
+	for {
+		d, err := t.s.Next()
+		if err != nil {
+			return err
+		}
+
+		if d.TypeCode == ltv.End {
+			break
+		}
+
+		if err := t.value(&body, d); err != nil {
+			return err
+		}
+		if count++; uint64(count) > math.MaxUint32 {
+			return errTooLong
+		}
+	}
+
+	t.writeArrayHeader(w, count)
+	w.Write(body.Bytes())
+	return nil
+}
+
+// Transcode the remainder of a struct as a map, or as an extension value
+// if it follows the {"$ext": I8, "$data": U8 vector} convention.
+func (t *ltv2mp) structure(w *bytes.Buffer) error {
+	var body bytes.Buffer
+	count := 0
+
+	var extType int8
+	var data []byte
+	isExt := true
+
+	for {
+		d, err := t.s.Next()
+		if err != nil {
+			return err
+		}
+
+		if d.TypeCode == ltv.End {
+			break
+		}
+
+		keyBuf, err := t.readBytes(d)
+		if err != nil {
+			return err
+		}
+		key := string(keyBuf)
+
+		d, err = t.s.Next()
+		if err != nil {
+			return err
+		}
+
+		t.writeStrHeader(&body, len(keyBuf))
+		body.Write(keyBuf)
+
+		switch {
+		case count == 0 && key == ExtTypeKey && d.TypeCode == ltv.I8 && d.SizeCode == ltv.SizeSingle:
+			if err := t.s.ReadFull(t.scratch[:1]); err != nil {
+				return err
+			}
+			extType = int8(t.scratch[0])
+			t.writeInt(&body, int64(extType))
+
+		case count == 1 && isExt && key == ExtDataKey && isU8Vec(d):
+			if data, err = t.readBytes(d); err != nil {
+				return err
+			}
+			t.writeBinHeader(&body, len(data))
+			body.Write(data)
+
+		default:
+			isExt = false
+			if err := t.value(&body, d); err != nil {
+				return err
+			}
+		}
+
+		if count++; uint64(count) > math.MaxUint32 {
+			return errTooLong
+		}
+	}
+
+	if isExt && count == 2 {
+		t.writeExt(w, extType, data)
+		return nil
+	}
+
+	t.writeMapHeader(w, count)
+	w.Write(body.Bytes())
+	return nil
+}
+
+func isU8Vec(d ltv.LtvElementDesc) bool {
+	return d.TypeCode == ltv.U8 && d.SizeCode != ltv.SizeSingle
+}
+
+// Write an unsigned integer in its most compact form.
+func (t *ltv2mp) writeUint(w *bytes.Buffer, v uint64) {
+	switch {
+	case v <= 0x7f:
+		w.WriteByte(byte(v))
+	case v <= math.MaxUint8:
+		w.WriteByte(mpUint8)
+		w.WriteByte(byte(v))
+	case v <= math.MaxUint16:
+		t.writeSized(w, mpUint16, 2, v)
+	case v <= math.MaxUint32:
+		t.writeSized(w, mpUint32, 4, v)
+	default:
+		t.writeSized(w, mpUint64, 8, v)
+	}
+}
+
+// Write a signed integer in its most compact form.
+func (t *ltv2mp) writeInt(w *bytes.Buffer, v int64) {
+	switch {
+	case v >= 0:
+		t.writeUint(w, uint64(v))
+	case v >= -32:
+		w.WriteByte(byte(v))
+	case v >= math.MinInt8:
+		w.WriteByte(mpInt8)
+		w.WriteByte(byte(v))
+	case v >= math.MinInt16:
+		t.writeSized(w, mpInt16, 2, uint64(v))
+	case v >= math.MinInt32:
+		t.writeSized(w, mpInt32, 4, uint64(v))
+	default:
+		t.writeSized(w, mpInt64, 8, uint64(v))
+	}
+}
+
+// Write a format byte followed by a big endian value of the given width.
+func (t *ltv2mp) writeSized(w *bytes.Buffer, format byte, width int, v uint64) {
+	binary.BigEndian.PutUint64(t.scratch[1:], v)
+	t.scratch[8-width] = format
+	w.Write(t.scratch[8-width : 9])
+}
+
+func (t *ltv2mp) writeStrHeader(w *bytes.Buffer, n int) {
+	switch {
+	case n <= 31:
+		w.WriteByte(mpFixStr | byte(n))
+	case n <= math.MaxUint8:
+		t.writeSized(w, mpStr8, 1, uint64(n))
+	case n <= math.MaxUint16:
+		t.writeSized(w, mpStr16, 2, uint64(n))
+	default:
+		t.writeSized(w, mpStr32, 4, uint64(n))
+	}
+}
+
+func (t *ltv2mp) writeBinHeader(w *bytes.Buffer, n int) {
+	switch {
+	case n <= math.MaxUint8:
+		t.writeSized(w, mpBin8, 1, uint64(n))
+	case n <= math.MaxUint16:
+		t.writeSized(w, mpBin16, 2, uint64(n))
+	default:
+		t.writeSized(w, mpBin32, 4, uint64(n))
+	}
+}
+
+func (t *ltv2mp) writeArrayHeader(w *bytes.Buffer, n int) {
+	switch {
+	case n <= 15:
+		w.WriteByte(mpFixArray | byte(n))
+	case n <= math.MaxUint16:
+		t.writeSized(w, mpArray16, 2, uint64(n))
+	default:
+		t.writeSized(w, mpArray32, 4, uint64(n))
+	}
+}
+
+func (t *ltv2mp) writeMapHeader(w *bytes.Buffer, n int) {
+	switch {
+	case n <= 15:
+		w.WriteByte(mpFixMap | byte(n))
+	case n <= math.MaxUint16:
+		t.writeSized(w, mpMap16, 2, uint64(n))
+	default:
+		t.writeSized(w, mpMap32, 4, uint64(n))
+	}
+}
+
+func (t *ltv2mp) writeExt(w *bytes.Buffer, extType int8, data []byte) {
+	switch len(data) {
+	case 1:
+		w.WriteByte(mpFixExt1)
+	case 2:
+		w.WriteByte(mpFixExt2)
+	case 4:
+		w.WriteByte(mpFixExt4)
+	case 8:
+		w.WriteByte(mpFixExt8)
+	case 16:
+		w.WriteByte(mpFixExt16)
+	default:
+		switch n := len(data); {
+		case n <= math.MaxUint8:
+			t.writeSized(w, mpExt8, 1, uint64(n))
+		case n <= math.MaxUint16:
+			t.writeSized(w, mpExt16, 2, uint64(n))
+		default:
+			t.writeSized(w, mpExt32, 4, uint64(n))
+		}
+	}
+
+	w.WriteByte(byte(extType))
+	w.Write(data)
+}
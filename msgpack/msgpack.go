@@ -0,0 +1,118 @@
+// Package msgpack transcodes between MessagePack and LiteVector.
+//
+// MessagePack values map onto LiteVector elements as follows:
+//
+//	nil             Nil
+//	bool            Bool
+//	fixint          the smallest signed integer type holding the value
+//	uint 8/16/32/64 U8/U16/U32/U64
+//	int 8/16/32/64  I8/I16/I32/I64
+//	float 32/64     F32/F64
+//	str             String
+//	bin             U8 vector
+//	array           a typed vector if the elements are homogeneous numbers
+//	                or booleans, otherwise a List
+//	map             Struct (integer keys are converted to decimal strings)
+//	ext             Struct {"$ext": I8 type, "$data": U8 vector}
+//
+// Integer arrays are packed into the narrowest vector type that holds every
+// element, using the same Goldilocks fitting as the JSON transcoder, so an
+// array of small non-negative integers becomes a U8 vector. Float arrays
+// become F32 vectors when every element is a float 32, otherwise F64.
+//
+// Going the other way, U8 vectors are written as bin, other vectors as
+// arrays, and the ext struct as an ext value. Binary and extension data
+// round trip, while an array that was packed into a U8 vector comes back
+// as bin.
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Keys of the struct used to represent a MessagePack extension value.
+const (
+	ExtTypeKey = "$ext"
+	ExtDataKey = "$data"
+)
+
+// MessagePack format bytes
+const (
+	mpNil      byte = 0xc0
+	mpFalse    byte = 0xc2
+	mpTrue     byte = 0xc3
+	mpBin8     byte = 0xc4
+	mpBin16    byte = 0xc5
+	mpBin32    byte = 0xc6
+	mpExt8     byte = 0xc7
+	mpExt16    byte = 0xc8
+	mpExt32    byte = 0xc9
+	mpFloat32  byte = 0xca
+	mpFloat64  byte = 0xcb
+	mpUint8    byte = 0xcc
+	mpUint16   byte = 0xcd
+	mpUint32   byte = 0xce
+	mpUint64   byte = 0xcf
+	mpInt8     byte = 0xd0
+	mpInt16    byte = 0xd1
+	mpInt32    byte = 0xd2
+	mpInt64    byte = 0xd3
+	mpFixExt1  byte = 0xd4
+	mpFixExt2  byte = 0xd5
+	mpFixExt4  byte = 0xd6
+	mpFixExt8  byte = 0xd7
+	mpFixExt16 byte = 0xd8
+	mpStr8     byte = 0xd9
+	mpStr16    byte = 0xda
+	mpStr32    byte = 0xdb
+	mpArray16  byte = 0xdc
+	mpArray32  byte = 0xdd
+	mpMap16    byte = 0xde
+	mpMap32    byte = 0xdf
+
+	mpFixMap   byte = 0x80
+	mpFixArray byte = 0x90
+	mpFixStr   byte = 0xa0
+	mpNegFix   byte = 0xe0
+)
+
+var (
+	errBadFormat       = errors.New("msgpack: invalid format byte")
+	errBadUtf8         = errors.New("msgpack: string with invalid UTF-8 data")
+	errMaxNestingDepth = errors.New("msgpack: max nesting depth exceeded")
+	errTooLong         = errors.New("msgpack: value too long")
+)
+
+// An UnsupportedKeyError is returned when a MessagePack map has a key
+// that cannot be represented as a LiteVector struct key.
+type UnsupportedKeyError struct {
+	Kind string
+}
+
+func (e *UnsupportedKeyError) Error() string {
+	return fmt.Sprintf("msgpack: unsupported map key type: %s", e.Kind)
+}
+
+// Read n bytes, where n comes from the input and can't be trusted for an
+// allocation up front. The buffer grows as the data actually arrives, so a
+// bogus length fails at the end of the input instead.
+func readLen(r io.Reader, n int) ([]byte, error) {
+	const chunk = 4096
+
+	var buf bytes.Buffer
+	if n < chunk {
+		buf.Grow(n)
+	}
+
+	m, err := io.CopyN(&buf, r, int64(n))
+	if m < int64(n) && (err == nil || err == io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,542 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+type itemKind int
+
+const (
+	nilItem itemKind = iota
+	boolItem
+	fixIntItem
+	intItem
+	uintItem
+	float32Item
+	float64Item
+	strItem
+	binItem
+	arrayItem
+	mapItem
+	extItem
+)
+
+var itemKindNames = []string{
+	"nil",
+	"bool",
+	"fixint",
+	"int",
+	"uint",
+	"float32",
+	"float64",
+	"str",
+	"bin",
+	"array",
+	"map",
+	"ext",
+}
+
+func (k itemKind) String() string {
+	return itemKindNames[k]
+}
+
+// A decoded MessagePack item header.
+// Scalars carry their value, while str, bin, ext, array and map items carry
+// their length (in bytes, or in elements) with the content left in the stream.
+type item struct {
+	kind  itemKind
+	width int // Encoded size of sized integers: 1, 2, 4 or 8
+	b     bool
+	i     int64
+	u     uint64
+	f     float64
+	n     int
+	ext   int8
+}
+
+type mp2ltv struct {
+	r     *bufio.Reader
+	e     *ltv.StreamEncoder
+	depth int
+	buf   [8]byte
+}
+
+// Streaming MessagePack to LiteVector transcoder.
+func Msgpack2Ltv(r io.Reader, w io.Writer) error {
+
+	t := mp2ltv{
+		r: bufio.NewReader(r),
+		e: ltv.NewStreamEncoder(w),
+	}
+
+	for {
+
+		// Check for write errors in the last pass
+		if t.e.Werr != nil {
+			return t.e.Werr
+		}
+
+		b, err := t.r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		it, err := t.header(b)
+		if err != nil {
+			return err
+		}
+
+		if err := t.write(it); err != nil {
+			return err
+		}
+	}
+}
+
+// Read exactly len(buf) bytes from the stream.
+// Running out of data in the middle of an item is always unexpected.
+func (t *mp2ltv) readFull(buf []byte) error {
+	_, err := io.ReadFull(t.r, buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Read the next item header from the stream.
+func (t *mp2ltv) next() (item, error) {
+	b, err := t.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return item{}, err
+	}
+	return t.header(b)
+}
+
+// Read a big endian unsigned integer of the given width.
+func (t *mp2ltv) readUint(width int) (uint64, error) {
+	buf := t.buf[:width]
+	if err := t.readFull(buf); err != nil {
+		return 0, err
+	}
+
+	switch width {
+	case 1:
+		return uint64(buf[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(buf)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(buf)), nil
+	default:
+		return binary.BigEndian.Uint64(buf), nil
+	}
+}
+
+// Read a length field of the given width
+func (t *mp2ltv) readLen(width int) (int, error) {
+	n, err := t.readUint(width)
+	return int(n), err
+}
+
+// Decode an item header given its format byte.
+func (t *mp2ltv) header(b byte) (it item, err error) {
+
+	switch {
+	case b <= 0x7f:
+		return item{kind: fixIntItem, i: int64(b)}, nil
+	case b >= mpNegFix:
+		return item{kind: fixIntItem, i: int64(int8(b))}, nil
+	case b&0xf0 == mpFixMap:
+		return item{kind: mapItem, n: int(b & 0x0f)}, nil
+	case b&0xf0 == mpFixArray:
+		return item{kind: arrayItem, n: int(b & 0x0f)}, nil
+	case b&0xe0 == mpFixStr:
+		return item{kind: strItem, n: int(b & 0x1f)}, nil
+	}
+
+	switch b {
+	case mpNil:
+		it.kind = nilItem
+	case mpFalse, mpTrue:
+		it.kind = boolItem
+		it.b = b == mpTrue
+
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		it.kind = uintItem
+		it.width = 1 << (b - mpUint8)
+		it.u, err = t.readUint(it.width)
+
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		var u uint64
+		it.kind = intItem
+		it.width = 1 << (b - mpInt8)
+		u, err = t.readUint(it.width)
+		switch it.width {
+		case 1:
+			it.i = int64(int8(u))
+		case 2:
+			it.i = int64(int16(u))
+		case 4:
+			it.i = int64(int32(u))
+		default:
+			it.i = int64(u)
+		}
+
+	case mpFloat32:
+		var u uint64
+		it.kind = float32Item
+		u, err = t.readUint(4)
+		it.f = float64(math.Float32frombits(uint32(u)))
+	case mpFloat64:
+		var u uint64
+		it.kind = float64Item
+		u, err = t.readUint(8)
+		it.f = math.Float64frombits(u)
+
+	case mpStr8, mpStr16, mpStr32:
+		it.kind = strItem
+		it.n, err = t.readLen(1 << (b - mpStr8))
+	case mpBin8, mpBin16, mpBin32:
+		it.kind = binItem
+		it.n, err = t.readLen(1 << (b - mpBin8))
+	case mpArray16, mpArray32:
+		it.kind = arrayItem
+		it.n, err = t.readLen(2 << (b - mpArray16))
+	case mpMap16, mpMap32:
+		it.kind = mapItem
+		it.n, err = t.readLen(2 << (b - mpMap16))
+
+	case mpFixExt1, mpFixExt2, mpFixExt4, mpFixExt8, mpFixExt16:
+		var u uint64
+		it.kind = extItem
+		it.n = 1 << (b - mpFixExt1)
+		u, err = t.readUint(1)
+		it.ext = int8(u)
+	case mpExt8, mpExt16, mpExt32:
+		var u uint64
+		it.kind = extItem
+		if it.n, err = t.readLen(1 << (b - mpExt8)); err != nil {
+			return it, err
+		}
+		u, err = t.readUint(1)
+		it.ext = int8(u)
+
+	default:
+		err = errBadFormat
+	}
+
+	return it, err
+}
+
+// Write an item (and its content) to the LiteVector stream.
+func (t *mp2ltv) write(it item) error {
+	e := t.e
+
+	switch it.kind {
+	case nilItem:
+		e.WriteNil()
+	case boolItem:
+		e.WriteBool(it.b)
+	case fixIntItem:
+		e.WriteInt(it.i)
+
+	case intItem:
+		switch it.width {
+		case 1:
+			e.WriteI8(int8(it.i))
+		case 2:
+			e.WriteI16(int16(it.i))
+		case 4:
+			e.WriteI32(int32(it.i))
+		default:
+			e.WriteI64(it.i)
+		}
+
+	case uintItem:
+		switch it.width {
+		case 1:
+			e.WriteU8(uint8(it.u))
+		case 2:
+			e.WriteU16(uint16(it.u))
+		case 4:
+			e.WriteU32(uint32(it.u))
+		default:
+			e.WriteU64(it.u)
+		}
+
+	case float32Item:
+		e.WriteF32(float32(it.f))
+	case float64Item:
+		e.WriteF64(it.f)
+
+	case strItem:
+		s, err := t.readString(it.n)
+		if err != nil {
+			return err
+		}
+		e.WriteString(s)
+
+	case binItem:
+		return t.copyBytes(it.n)
+
+	case extItem:
+		e.WriteStructStart()
+		e.WriteString(ExtTypeKey)
+		e.WriteI8(it.ext)
+		e.WriteString(ExtDataKey)
+		if err := t.copyBytes(it.n); err != nil {
+			return err
+		}
+		e.WriteStructEnd()
+
+	case arrayItem:
+		return t.array(it.n)
+	case mapItem:
+		return t.mapping(it.n)
+	}
+
+	return nil
+}
+
+// Read a string of n bytes from the stream.
+func (t *mp2ltv) readString(n int) (string, error) {
+	buf, err := readLen(t.r, n)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(buf) {
+		return "", errBadUtf8
+	}
+	return string(buf), nil
+}
+
+// Copy n bytes of binary data from the stream as a U8 vector.
+func (t *mp2ltv) copyBytes(n int) error {
+	var buf [4096]byte
+
+	t.e.WriteVectorPrefix(ltv.U8, n)
+	for n > 0 {
+		chunk := buf[:]
+		if n < len(chunk) {
+			chunk = chunk[:n]
+		}
+		if err := t.readFull(chunk); err != nil {
+			return err
+		}
+		t.e.RawWrite(chunk)
+		n -= len(chunk)
+	}
+
+	return t.e.Werr
+}
+
+func (t *mp2ltv) push() error {
+	t.depth++
+	if t.depth >= ltv.MaxNestingDepth {
+		return errMaxNestingDepth
+	}
+	return nil
+}
+
+// Transcode an array of n elements, packing it into a vector if possible.
+func (t *mp2ltv) array(n int) error {
+	if err := t.push(); err != nil {
+		return err
+	}
+
+	var vec vectorizer
+	collapsed := false
+
+	for i := 0; i < n; i++ {
+		it, err := t.next()
+		if err != nil {
+			return err
+		}
+
+		if !collapsed {
+			if vec.add(it) {
+				continue
+			}
+
+			// This element breaks vector candidacy. Write what we have so far as a list.
+			collapsed = true
+			t.e.WriteListStart()
+			for _, pending := range vec.items {
+				if err := t.write(pending); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := t.write(it); err != nil {
+			return err
+		}
+	}
+
+	if collapsed || len(vec.items) == 0 {
+		if !collapsed {
+			t.e.WriteListStart()
+		}
+		t.e.WriteListEnd()
+	} else {
+		vec.writeVector(t.e)
+	}
+
+	t.depth--
+	return nil
+}
+
+// Transcode a map of n key/value pairs as a struct.
+func (t *mp2ltv) mapping(n int) error {
+	if err := t.push(); err != nil {
+		return err
+	}
+
+	t.e.WriteStructStart()
+
+	for i := 0; i < n; i++ {
+		key, err := t.next()
+		if err != nil {
+			return err
+		}
+
+		switch key.kind {
+		case strItem:
+			s, err := t.readString(key.n)
+			if err != nil {
+				return err
+			}
+			t.e.WriteString(s)
+		case fixIntItem, intItem:
+			t.e.WriteString(strconv.FormatInt(key.i, 10))
+		case uintItem:
+			t.e.WriteString(strconv.FormatUint(key.u, 10))
+		default:
+			return &UnsupportedKeyError{key.kind.String()}
+		}
+
+		val, err := t.next()
+		if err != nil {
+			return err
+		}
+
+		if err := t.write(val); err != nil {
+			return err
+		}
+	}
+
+	t.e.WriteStructEnd()
+	t.depth--
+	return nil
+}
+
+// Accumulates array elements while they are candidates for a typed vector.
+type vectorizer struct {
+	items []item
+	class itemKind // boolItem, intItem, or float64Item
+	neg   bool     // Holds a negative integer
+	big   bool     // Holds an unsigned integer larger than MaxInt64
+	f64   bool     // Holds a float 64
+}
+
+// Add an item to the vector.
+// Returns false if the item can't be part of a homogeneous vector.
+func (v *vectorizer) add(it item) bool {
+	var class itemKind
+
+	switch it.kind {
+	case boolItem:
+		class = boolItem
+	case fixIntItem, intItem:
+		class = intItem
+		if it.i < 0 {
+			if v.big {
+				return false
+			}
+			v.neg = true
+		}
+	case uintItem:
+		class = intItem
+		if it.u > math.MaxInt64 {
+			if v.neg {
+				return false
+			}
+			v.big = true
+		}
+	case float32Item:
+		class = float64Item
+	case float64Item:
+		class = float64Item
+		v.f64 = true
+	default:
+		return false
+	}
+
+	if len(v.items) > 0 && class != v.class {
+		return false
+	}
+
+	v.class = class
+	v.items = append(v.items, it)
+	return true
+}
+
+// Integer value of an integer item
+func (it item) uint64() uint64 {
+	if it.kind == uintItem {
+		return it.u
+	}
+	return uint64(it.i)
+}
+
+func (v *vectorizer) writeVector(e *ltv.StreamEncoder) {
+	switch v.class {
+	case boolItem:
+		vec := make([]bool, len(v.items))
+		for i, it := range v.items {
+			vec[i] = it.b
+		}
+		e.WriteBoolVec(vec)
+
+	case intItem:
+		if v.neg {
+			vec := make([]int64, len(v.items))
+			for i, it := range v.items {
+				vec[i] = int64(it.uint64())
+			}
+			e.WriteIntVec(vec)
+		} else {
+			vec := make([]uint64, len(v.items))
+			for i, it := range v.items {
+				vec[i] = it.uint64()
+			}
+			e.WriteUintVec(vec)
+		}
+
+	case float64Item:
+		if v.f64 {
+			vec := make([]float64, len(v.items))
+			for i, it := range v.items {
+				vec[i] = it.f
+			}
+			e.WriteF64Vec(vec)
+		} else {
+			vec := make([]float32, len(v.items))
+			for i, it := range v.items {
+				vec[i] = float32(it.f)
+			}
+			e.WriteF32Vec(vec)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Transcode MessagePack to LiteVector and decode the result
+func msgpackValue(t *testing.T, mp []byte) any {
+	var buf bytes.Buffer
+	if err := Msgpack2Ltv(bytes.NewReader(mp), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ltv.NewDecoder(buf.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMsgpackScalars(t *testing.T) {
+	tests := []struct {
+		mp   string
+		want any
+	}{
+		{"c0", nil},
+		{"c3", true},
+		{"05", int8(5)},
+		{"e0", int8(-32)},
+		{"cc05", uint8(5)},
+		{"cd0100", uint16(256)},
+		{"d0fe", int8(-2)},
+		{"d3fffffffffffffffe", int64(-2)},
+		{"ca3fc00000", float32(1.5)},
+		{"cb3ff8000000000000", float64(1.5)},
+		{"a3616263", "abc"},
+		{"c403010203", []byte{1, 2, 3}},
+	}
+
+	for _, test := range tests {
+		got := msgpackValue(t, mustDecodeHex(t, test.mp))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.mp, got, test.want)
+		}
+	}
+}
+
+func TestMsgpackVectors(t *testing.T) {
+	tests := []struct {
+		mp   string
+		want any
+	}{
+		// Goldilocks fitting of integer arrays
+		{"93010203", []uint8{1, 2, 3}},
+		{"92ccc801", []uint8{200, 1}},
+		{"9301cd01007f", []uint16{1, 256, 127}},
+		{"93ff0102", []int8{-1, 1, 2}},
+		{"92ffcd0100", []int16{-1, 256}},
+
+		// Floats
+		{"92ca3fc00000ca3fc00000", []float32{1.5, 1.5}},
+		{"92ca3fc00000cb3ff8000000000000", []float64{1.5, 1.5}},
+
+		// Booleans
+		{"92c3c2", []bool{true, false}},
+
+		// Mixed arrays collapse to lists
+		{"9201a161", []any{int8(1), "a"}},
+		{"9201c0", []any{int8(1), nil}},
+		{"90", []any{}},
+	}
+
+	for _, test := range tests {
+		got := msgpackValue(t, mustDecodeHex(t, test.mp))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.mp, got, test.want)
+		}
+	}
+}
+
+func TestMsgpackMapAndExt(t *testing.T) {
+	// {"a": 1, 2: "b"}
+	got := msgpackValue(t, mustDecodeHex(t, "82a1610102a162"))
+	want := map[string]any{"a": int8(1), "2": "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	// fixext 4, type -1 (timestamp)
+	got = msgpackValue(t, mustDecodeHex(t, "d6ff01020304"))
+	want = map[string]any{ExtTypeKey: int8(-1), ExtDataKey: []byte{1, 2, 3, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+
+	// Unsupported key
+	var buf bytes.Buffer
+	err := Msgpack2Ltv(bytes.NewReader(mustDecodeHex(t, "81c001")), &buf)
+	if _, ok := err.(*UnsupportedKeyError); !ok {
+		t.Fatalf("expected UnsupportedKeyError, got %v", err)
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+
+	type Sample struct {
+		Name    string
+		Count   int
+		Neg     int64
+		Big     uint64
+		Ratio   float64
+		Samples []float32
+		Raw     []byte
+		Flags   []bool
+		List    []any
+		Sub     map[string]any
+	}
+
+	v1 := Sample{
+		Name:    "Sensor",
+		Count:   70000,
+		Neg:     -5,
+		Big:     math.MaxUint64,
+		Ratio:   0.25,
+		Samples: []float32{1.5, -2.5, 3},
+		Raw:     []byte("raw bytes"),
+		Flags:   []bool{true, false, true},
+		List:    []any{"a", 1, nil},
+		Sub:     map[string]any{"x": "y"},
+	}
+
+	lv1, err := ltv.Marshal(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// LTV -> MessagePack
+	var mp bytes.Buffer
+	if err := Ltv2Msgpack(bytes.NewReader(lv1), &mp); err != nil {
+		t.Fatal(err)
+	}
+
+	// MessagePack -> LTV
+	var lv2 bytes.Buffer
+	if err := Msgpack2Ltv(&mp, &lv2); err != nil {
+		t.Fatal(err)
+	}
+
+	var v2 Sample
+	if err := ltv.Unmarshal(lv2.Bytes(), &v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("roundtrip mismatch:\n%#v\n%#v", v1, v2)
+	}
+}
+
+func TestMsgpackExtRoundTrip(t *testing.T) {
+	mp1 := mustDecodeHex(t, "92d6ff01020304c70305616263")
+
+	var lv bytes.Buffer
+	if err := Msgpack2Ltv(bytes.NewReader(mp1), &lv); err != nil {
+		t.Fatal(err)
+	}
+
+	var mp2 bytes.Buffer
+	if err := Ltv2Msgpack(&lv, &mp2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(mp1, mp2.Bytes()) {
+		t.Fatalf("roundtrip mismatch: %x != %x", mp1, mp2.Bytes())
+	}
+}
+
+func TestMsgpackArrayBinRoundTrip(t *testing.T) {
+	// [1, 256], [-1, 2] and bin [1, 2, 3]
+	mp1 := mustDecodeHex(t, "93"+"9201cd0100"+"92ff02"+"c403010203")
+
+	var lv bytes.Buffer
+	if err := Msgpack2Ltv(bytes.NewReader(mp1), &lv); err != nil {
+		t.Fatal(err)
+	}
+
+	var mp2 bytes.Buffer
+	if err := Ltv2Msgpack(&lv, &mp2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(mp1, mp2.Bytes()) {
+		t.Fatalf("roundtrip mismatch: %x != %x", mp1, mp2.Bytes())
+	}
+
+	// An array packed into a U8 vector comes back as bin
+	lv.Reset()
+	mp2.Reset()
+	if err := Msgpack2Ltv(bytes.NewReader(mustDecodeHex(t, "92ccc801")), &lv); err != nil {
+		t.Fatal(err)
+	}
+	if err := Ltv2Msgpack(&lv, &mp2); err != nil {
+		t.Fatal(err)
+	}
+	if want := mustDecodeHex(t, "c402c801"); !bytes.Equal(mp2.Bytes(), want) {
+		t.Fatalf("got %x, want %x", mp2.Bytes(), want)
+	}
+}
+
+func TestMsgpackBogusLength(t *testing.T) {
+	// str 32 and bin 32 claiming 4GB, with 3 bytes of data
+	for _, mp := range []string{"dbffffffff616263", "c6ffffffff010203"} {
+		var buf bytes.Buffer
+		err := Msgpack2Ltv(bytes.NewReader(mustDecodeHex(t, mp)), &buf)
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: expected ErrUnexpectedEOF, got %v", mp, err)
+		}
+	}
+
+	// A vector of more elements than a MessagePack array can hold
+	e := ltv.NewEncoder()
+	e.WriteTag(ltv.U8, ltv.Size8)
+	e.RawWriteUint64(math.MaxUint32 + 1)
+	if err := Ltv2Msgpack(bytes.NewReader(e.Bytes()), new(bytes.Buffer)); err != errTooLong {
+		t.Errorf("expected errTooLong, got %v", err)
+	}
+
+	// A string vector claiming 4GB
+	e = ltv.NewEncoder()
+	e.WriteTag(ltv.String, ltv.Size4)
+	e.RawWriteUint32(math.MaxUint32)
+	e.RawWrite([]byte("abc"))
+	var buf bytes.Buffer
+	if err := Ltv2Msgpack(bytes.NewReader(e.Bytes()), &buf); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package npy
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+// An array extracted from a LiteVector document
+type array struct {
+	shape []int
+	data  any // A typed slice
+}
+
+// LiteVector types of the typed slices returned by the decoder
+var sliceTypes = map[reflect.Type]ltv.TypeCode{
+	reflect.TypeOf([]bool{}):    ltv.Bool,
+	reflect.TypeOf([]uint8{}):   ltv.U8,
+	reflect.TypeOf([]uint16{}):  ltv.U16,
+	reflect.TypeOf([]uint32{}):  ltv.U32,
+	reflect.TypeOf([]uint64{}):  ltv.U64,
+	reflect.TypeOf([]int8{}):    ltv.I8,
+	reflect.TypeOf([]int16{}):   ltv.I16,
+	reflect.TypeOf([]int32{}):   ltv.I32,
+	reflect.TypeOf([]int64{}):   ltv.I64,
+	reflect.TypeOf([]float32{}): ltv.F32,
+	reflect.TypeOf([]float64{}): ltv.F64,
+}
+
+// Read the first value of a LiteVector document.
+func readDocument(r io.Reader) (any, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ltv.NewDecoder(buf).Value()
+}
+
+// Follow a dotted key path (with numeric list indices) into a document.
+func lookup(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			val, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("npy: key not found: %q", path)
+			}
+			v = val
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("npy: index not found: %q", path)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("npy: key not found: %q", path)
+		}
+	}
+
+	return v, nil
+}
+
+// Convert an integer vector or list to a shape
+func toShape(v any) ([]int, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+
+	shape := make([]int, rv.Len())
+	for i := range shape {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Interface {
+			elem = elem.Elem()
+		}
+
+		switch elem.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if elem.Int() < 0 {
+				return nil, false
+			}
+			shape[i] = int(elem.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if elem.Uint() > math.MaxInt {
+				return nil, false
+			}
+			shape[i] = int(elem.Uint())
+		default:
+			return nil, false
+		}
+	}
+
+	return shape, true
+}
+
+// Interpret a value as an array.
+// Typed vectors are one dimensional arrays, and array structs provide
+// their own shape. Returns false if the value is neither.
+func toArray(v any) (array, bool, error) {
+	if _, ok := sliceTypes[reflect.TypeOf(v)]; ok {
+		return array{shape: []int{reflect.ValueOf(v).Len()}, data: v}, true, nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return array{}, false, nil
+	}
+
+	data, ok := m[DataKey]
+	if !ok {
+		return array{}, false, nil
+	}
+
+	if _, ok := sliceTypes[reflect.TypeOf(data)]; !ok {
+		return array{}, false, nil
+	}
+
+	count := reflect.ValueOf(data).Len()
+	a := array{shape: []int{count}, data: data}
+
+	if s, ok := m[ShapeKey]; ok {
+		shape, ok := toShape(s)
+		if !ok {
+			return a, false, errBadShape
+		}
+		a.shape = shape
+		n, err := (Header{Shape: shape}).Count()
+		if err != nil {
+			return a, false, err
+		}
+		if n != count {
+			return a, false, errShapeMismatch
+		}
+	}
+
+	return a, true, nil
+}
+
+// Write an array as a .npy file
+func writeNpy(w io.Writer, a array) error {
+	descr, _ := Descr(sliceTypes[reflect.TypeOf(a.data)])
+	if err := WriteHeader(w, Header{Descr: descr, Shape: a.shape}); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, a.data)
+}
+
+// Extract a vector from a LiteVector document and write it as a .npy file.
+//
+// The path is a dotted key path (with numeric list indices) to the value
+// within the document. An empty path selects the whole document. The value
+// may be a typed vector, written as a one dimensional array, or an array
+// struct with a data vector and an optional shape.
+func Ltv2Npy(r io.Reader, w io.Writer, path string) error {
+	doc, err := readDocument(r)
+	if err != nil {
+		return err
+	}
+
+	v, err := lookup(doc, path)
+	if err != nil {
+		return err
+	}
+
+	a, ok, err := toArray(v)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNotArray
+	}
+
+	return writeNpy(w, a)
+}
+
+// Extract every vector (or array struct) from a LiteVector document
+// and write them as a .npz archive. Members are named by the dotted
+// key path to each array, with a top level array named "arr_0".
+func Ltv2Npz(r io.Reader, w io.Writer) error {
+	doc, err := readDocument(r)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := writeNpzMembers(zw, doc, ""); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeNpzMembers(zw *zip.Writer, v any, path string) error {
+	a, ok, err := toArray(v)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if path == "" {
+			path = "arr_0"
+		}
+
+		fw, err := zw.Create(path + ".npy")
+		if err != nil {
+			return err
+		}
+		return writeNpy(fw, a)
+	}
+
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+
+	switch node := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := writeNpzMembers(zw, node[k], prefix+k); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, elem := range node {
+			if err := writeNpzMembers(zw, elem, prefix+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+// Package npy converts between NumPy .npy/.npz files and LiteVector.
+//
+// A NumPy array is represented in LiteVector as a struct:
+//
+//	{"dtype": String, "shape": U64 vector, "data": typed vector}
+//
+// where data holds the array elements in C (row major) order, and dtype is
+// the NumPy type descriptor of the data ("<f4", "|u1", "|b1", ...).
+package npy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+// Keys of the struct used to represent an array.
+const (
+	DtypeKey = "dtype"
+	ShapeKey = "shape"
+	DataKey  = "data"
+)
+
+const npyMagic = "\x93NUMPY"
+
+// Longest header accepted by ReadHeader. This is NumPy's own limit, without
+// allow_pickle or max_header_size, and bounds the allocation for a header
+// length read from the file.
+const MaxHeaderLength = 10000
+
+var (
+	errBadMagic      = errors.New("npy: not a .npy file")
+	errBadHeader     = errors.New("npy: invalid .npy header")
+	errHeaderTooLong = errors.New("npy: .npy header too long")
+	errFortranOrder  = errors.New("npy: fortran ordered arrays are not supported")
+	errBadShape      = errors.New("npy: invalid array shape")
+	errShapeMismatch = errors.New("npy: shape does not match data length")
+	errNotArray      = errors.New("npy: value is not a vector or array struct")
+	errBadName       = errors.New("npy: archive member name is not valid UTF-8")
+)
+
+// An UnsupportedDtypeError is returned for NumPy dtypes
+// with no LiteVector equivalent.
+type UnsupportedDtypeError struct {
+	Descr string
+}
+
+func (e *UnsupportedDtypeError) Error() string {
+	return "npy: unsupported dtype: " + e.Descr
+}
+
+// A .npy file header
+type Header struct {
+	Descr        string // NumPy type descriptor, e.g. "<f4"
+	FortranOrder bool
+	Shape        []int
+}
+
+// The number of elements described by the header shape.
+// Returns an error for a negative dimension, or a count which overflows int.
+func (h Header) Count() (int, error) {
+	for _, dim := range h.Shape {
+		if dim < 0 {
+			return 0, errBadShape
+		}
+		if dim == 0 {
+			return 0, nil
+		}
+	}
+
+	count := 1
+	for _, dim := range h.Shape {
+		if count > math.MaxInt/dim {
+			return 0, errBadShape
+		}
+		count *= dim
+	}
+	return count, nil
+}
+
+// NumPy type characters for each LiteVector type
+var typeChars = map[ltv.TypeCode]string{
+	ltv.Bool: "b1",
+	ltv.U8:   "u1",
+	ltv.U16:  "u2",
+	ltv.U32:  "u4",
+	ltv.U64:  "u8",
+	ltv.I8:   "i1",
+	ltv.I16:  "i2",
+	ltv.I32:  "i4",
+	ltv.I64:  "i8",
+	ltv.F32:  "f4",
+	ltv.F64:  "f8",
+}
+
+// The little endian NumPy type descriptor for a LiteVector type.
+func Descr(t ltv.TypeCode) (string, bool) {
+	c, ok := typeChars[t]
+	if !ok {
+		return "", false
+	}
+	if t.Size() == 1 {
+		return "|" + c, true
+	}
+	return "<" + c, true
+}
+
+// Parse a NumPy type descriptor.
+// Returns the LiteVector type and whether the data is big endian.
+func parseDescr(descr string) (ltv.TypeCode, bool, error) {
+	if len(descr) != 3 {
+		return 0, false, &UnsupportedDtypeError{descr}
+	}
+
+	bigEndian := false
+	switch descr[0] {
+	case '<', '|', '=':
+	case '>':
+		bigEndian = true
+	default:
+		return 0, false, &UnsupportedDtypeError{descr}
+	}
+
+	for t, c := range typeChars {
+		if c == descr[1:] {
+			return t, bigEndian && t.Size() > 1, nil
+		}
+	}
+
+	return 0, false, &UnsupportedDtypeError{descr}
+}
+
+var (
+	descrPattern   = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	fortranPattern = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	shapePattern   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// Read and parse a .npy header, leaving r positioned at the array data.
+func ReadHeader(r io.Reader) (Header, error) {
+	var h Header
+	var prefix [8]byte
+
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return h, err
+	}
+
+	if string(prefix[:6]) != npyMagic {
+		return h, errBadMagic
+	}
+
+	// Version 1.0 uses a 2 byte header length, later versions use 4 bytes.
+	var hlen int
+	if prefix[6] == 1 {
+		var buf [2]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return h, err
+		}
+		hlen = int(binary.LittleEndian.Uint16(buf[:]))
+	} else {
+		var buf [4]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return h, err
+		}
+		hlen = int(binary.LittleEndian.Uint32(buf[:]))
+	}
+
+	if hlen > MaxHeaderLength {
+		return h, errHeaderTooLong
+	}
+
+	buf := make([]byte, hlen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return h, err
+	}
+
+	m := descrPattern.FindSubmatch(buf)
+	if m == nil {
+		return h, errBadHeader
+	}
+	h.Descr = string(m[1])
+
+	m = fortranPattern.FindSubmatch(buf)
+	if m == nil {
+		return h, errBadHeader
+	}
+	h.FortranOrder = string(m[1]) == "True"
+
+	m = shapePattern.FindSubmatch(buf)
+	if m == nil {
+		return h, errBadHeader
+	}
+
+	h.Shape = []int{}
+	for _, dim := range strings.Split(string(m[1]), ",") {
+		dim = strings.TrimSpace(dim)
+		if dim == "" {
+			continue
+		}
+		n, err := strconv.Atoi(dim)
+		if err != nil || n < 0 {
+			return h, errBadHeader
+		}
+		h.Shape = append(h.Shape, n)
+	}
+
+	return h, nil
+}
+
+// Write a .npy header.
+func WriteHeader(w io.Writer, h Header) error {
+	var shape bytes.Buffer
+	shape.WriteByte('(')
+	for i, dim := range h.Shape {
+		if i > 0 {
+			shape.WriteString(", ")
+		}
+		shape.WriteString(strconv.Itoa(dim))
+	}
+	if len(h.Shape) == 1 {
+		shape.WriteByte(',')
+	}
+	shape.WriteByte(')')
+
+	fortran := "False"
+	if h.FortranOrder {
+		fortran = "True"
+	}
+
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': %s, 'shape': %s, }", h.Descr, fortran, shape.String())
+
+	// The header is padded with spaces and a terminating newline
+	// so the array data starts on a 64 byte boundary.
+	prefixLen := len(npyMagic) + 2 + 2
+	version := byte(1)
+	if len(dict)+1+prefixLen+64 > 0xffff {
+		prefixLen += 2
+		version = 2
+	}
+
+	total := prefixLen + len(dict) + 1
+	padding := (64 - total%64) % 64
+	header := dict + strings.Repeat(" ", padding) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.WriteByte(version)
+	buf.WriteByte(0)
+	if version == 1 {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(header)))
+	}
+	buf.WriteString(header)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
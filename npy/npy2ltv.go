@@ -0,0 +1,133 @@
+package npy
+
+import (
+	"archive/zip"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+// Size of the buffer used to stream array data
+const copyBufferSize = 64 * 1024
+
+// Streaming .npy to LiteVector converter.
+// The array data is copied straight into an aligned LiteVector vector,
+// without loading the whole array in memory.
+func Npy2Ltv(r io.Reader, w io.Writer) error {
+	e := ltv.NewStreamEncoder(w)
+	if err := writeArray(e, r); err != nil {
+		return err
+	}
+	return e.Werr
+}
+
+// Convert a .npz archive to a LiteVector struct of arrays,
+// keyed by the archive member names (without the .npy extension).
+func Npz2Ltv(r io.ReaderAt, size int64, w io.Writer) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+
+	e := ltv.NewStreamEncoder(w)
+	e.WriteStructStart()
+
+	for _, f := range zr.File {
+		if !utf8.ValidString(f.Name) {
+			return errBadName
+		}
+		e.WriteString(strings.TrimSuffix(f.Name, ".npy"))
+
+		fr, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		err = writeArray(e, fr)
+		fr.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	e.WriteStructEnd()
+	return e.Werr
+}
+
+// Read a .npy stream and write it as an array struct.
+func writeArray(e *ltv.StreamEncoder, r io.Reader) error {
+	h, err := ReadHeader(r)
+	if err != nil {
+		return err
+	}
+
+	if h.FortranOrder {
+		return errFortranOrder
+	}
+
+	t, swap, err := parseDescr(h.Descr)
+	if err != nil {
+		return err
+	}
+
+	count, err := h.Count()
+	if err != nil {
+		return err
+	}
+	typeSize := t.Size()
+	if count > math.MaxInt/typeSize {
+		return errBadShape
+	}
+
+	descr, _ := Descr(t)
+	shape := make([]uint64, len(h.Shape))
+	for i, dim := range h.Shape {
+		shape[i] = uint64(dim)
+	}
+
+	e.WriteStructStart()
+	e.WriteString(DtypeKey)
+	e.WriteString(descr)
+	e.WriteString(ShapeKey)
+	e.WriteU64Vec(shape)
+	e.WriteString(DataKey)
+
+	e.WriteVectorPrefix(t, count)
+
+	buf := make([]byte, copyBufferSize)
+	for n := count * typeSize; n > 0; {
+		chunk := buf
+		if n < len(chunk) {
+			chunk = chunk[:n]
+		}
+
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		if swap {
+			for i := 0; i < len(chunk); i += typeSize {
+				reverse(chunk[i : i+typeSize])
+			}
+		}
+
+		e.RawWrite(chunk)
+		n -= len(chunk)
+	}
+
+	e.WriteStructEnd()
+	return e.Werr
+}
+
+// Byte swap in place
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
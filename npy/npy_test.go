@@ -0,0 +1,180 @@
+package npy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+
+	ltv "github.com/ThadThompson/ltvgo"
+)
+
+func makeNpy(t *testing.T, h Header, data any) []byte {
+	var buf bytes.Buffer
+	if err := WriteHeader(&buf, h); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len()%64 != 0 {
+		t.Fatalf("header not aligned: %d bytes", buf.Len())
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNpy2Ltv(t *testing.T) {
+	data := []float32{1, 2, 3, 4, 5, 6}
+	in := makeNpy(t, Header{Descr: "<f4", Shape: []int{2, 3}}, data)
+
+	var out bytes.Buffer
+	if err := Npy2Ltv(bytes.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ltv.NewDecoder(out.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		DtypeKey: "<f4",
+		ShapeKey: []uint64{2, 3},
+		DataKey:  data,
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v", v)
+	}
+
+	// Converting back gives the original file
+	var npyOut bytes.Buffer
+	if err := Ltv2Npy(&out, &npyOut, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(npyOut.Bytes(), in) {
+		t.Fatal("round trip mismatch")
+	}
+}
+
+func TestNpy2LtvBigEndian(t *testing.T) {
+	var buf bytes.Buffer
+	WriteHeader(&buf, Header{Descr: ">i2", Shape: []int{3}})
+	binary.Write(&buf, binary.BigEndian, []int16{1, -2, 300})
+
+	var out bytes.Buffer
+	if err := Npy2Ltv(&buf, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ltv.NewDecoder(out.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := v.(map[string]any)
+	if m[DtypeKey] != "<i2" || !reflect.DeepEqual(m[DataKey], []int16{1, -2, 300}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestNpyErrors(t *testing.T) {
+	in := makeNpy(t, Header{Descr: "<c8", Shape: []int{1}}, []float32{0, 0})
+	if err := Npy2Ltv(bytes.NewReader(in), new(bytes.Buffer)); err == nil {
+		t.Fatal("expected unsupported dtype error")
+	}
+
+	in = makeNpy(t, Header{Descr: "<f8", Shape: []int{4}}, []float64{1, 2})
+	if err := Npy2Ltv(bytes.NewReader(in), new(bytes.Buffer)); err == nil {
+		t.Fatal("expected short data error")
+	}
+
+	doc, _ := ltv.Marshal(map[string]any{"data": []uint16{1, 2, 3}, "shape": []int{2, 2}})
+	if err := Ltv2Npy(bytes.NewReader(doc), new(bytes.Buffer), ""); err != errShapeMismatch {
+		t.Fatalf("expected shape mismatch, got %v", err)
+	}
+
+	doc, _ = ltv.Marshal(map[string]any{"name": "x"})
+	if err := Ltv2Npy(bytes.NewReader(doc), new(bytes.Buffer), "name"); err != errNotArray {
+		t.Fatalf("expected not an array, got %v", err)
+	}
+
+	// Shapes with counts or lengths which would overflow
+	half := 1 << (strconv.IntSize / 2)
+	for _, shape := range [][]int{{half, half}, {math.MaxInt/2 + 1, 8}, {math.MaxInt/4 + 1}} {
+		in = makeNpy(t, Header{Descr: "<f8", Shape: shape}, []float64{1})
+		if err := Npy2Ltv(bytes.NewReader(in), new(bytes.Buffer)); err != errBadShape {
+			t.Fatalf("%v: expected bad shape, got %v", shape, err)
+		}
+	}
+	if _, err := (Header{Shape: []int{2, -1}}).Count(); err != errBadShape {
+		t.Fatalf("expected bad shape, got %v", err)
+	}
+
+	doc, _ = ltv.Marshal(map[string]any{"data": []uint16{1, 2, 3}, "shape": []uint64{1 << 63, 2}})
+	if err := Ltv2Npy(bytes.NewReader(doc), new(bytes.Buffer), ""); err != errBadShape {
+		t.Fatalf("expected bad shape, got %v", err)
+	}
+
+	// A version 2 header claiming 4GB
+	in = []byte(npyMagic + "\x02\x00\xff\xff\xff\xff")
+	if err := Npy2Ltv(bytes.NewReader(in), new(bytes.Buffer)); err != errHeaderTooLong {
+		t.Fatalf("expected header too long, got %v", err)
+	}
+
+	// Archive member names must be valid UTF-8
+	var npz bytes.Buffer
+	zw := zip.NewWriter(&npz)
+	fw, _ := zw.Create("\xff.npy")
+	fw.Write(makeNpy(t, Header{Descr: "|u1", Shape: []int{1}}, []uint8{1}))
+	zw.Close()
+	if err := Npz2Ltv(bytes.NewReader(npz.Bytes()), int64(npz.Len()), new(bytes.Buffer)); err != errBadName {
+		t.Fatalf("expected bad name, got %v", err)
+	}
+}
+
+func TestNpzRoundTrip(t *testing.T) {
+	doc, err := ltv.Marshal(map[string]any{
+		"a": []uint8{1, 2, 3},
+		"b": map[string]any{
+			"c": []float64{0.5, 1.5},
+		},
+		"name": "ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var npz bytes.Buffer
+	if err := Ltv2Npz(bytes.NewReader(doc), &npz); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Npz2Ltv(bytes.NewReader(npz.Bytes()), int64(npz.Len()), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ltv.NewDecoder(out.Bytes()).Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"a": map[string]any{
+			DtypeKey: "|u1",
+			ShapeKey: []uint64{3},
+			DataKey:  []uint8{1, 2, 3},
+		},
+		"b.c": map[string]any{
+			DtypeKey: "<f8",
+			ShapeKey: []uint64{2},
+			DataKey:  []float64{0.5, 1.5},
+		},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v", v)
+	}
+}
@@ -0,0 +1,191 @@
+package ltvgo
+
+import (
+	"math"
+	"reflect"
+)
+
+// A NumericPolicy controls which numeric conversions Unmarshal makes when
+// the encoded type of a value differs from the Go type it is stored in.
+// The policy applies to single values, vectors and arrays alike. Values
+// stored in interfaces keep their encoded types and are unaffected.
+type NumericPolicy int
+
+const (
+	// NumericConvert converts between integers of any width and signedness
+	// when the value fits the Go type, and between float32 and float64.
+	// This is the default.
+	NumericConvert NumericPolicy = iota
+
+	// NumericWiden only allows conversions which can represent every value
+	// of the encoded type: an integer into a wider integer (or an unsigned
+	// integer into a wider signed integer), and float32 into float64.
+	// Marshal narrows integers, so this is the strictest policy that accepts
+	// its output without MarshalOptions.FixedWidthInts.
+	NumericWiden
+
+	// NumericExact requires the encoded type to have the same signedness and
+	// width as the Go type. int and uint match the 64-bit types.
+	NumericExact
+)
+
+// The Go types of the encoded numeric types.
+var numericTypes = map[TypeCode]reflect.Type{
+	U8:  reflect.TypeOf(uint8(0)),
+	U16: reflect.TypeOf(uint16(0)),
+	U32: reflect.TypeOf(uint32(0)),
+	U64: reflect.TypeOf(uint64(0)),
+	I8:  reflect.TypeOf(int8(0)),
+	I16: reflect.TypeOf(int16(0)),
+	I32: reflect.TypeOf(int32(0)),
+	I64: reflect.TypeOf(int64(0)),
+	F32: reflect.TypeOf(float32(0)),
+	F64: reflect.TypeOf(float64(0)),
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// Check whether the numeric policy allows a value of the src type to be
+// stored in the dst type. Both must be integer or float types.
+// Whether the value itself fits is checked separately.
+func (d *decodeState) numericAllowed(src, dst reflect.Type) bool {
+	sk, dk := src.Kind(), dst.Kind()
+
+	// Floats and integers
+	if isFloatKind(sk) != isFloatKind(dk) {
+		return isFloatKind(sk) && d.integralFloats
+	}
+
+	// Floats
+	if isFloatKind(sk) {
+		switch {
+		case sk == dk:
+			return true
+		case dk == reflect.Float64:
+			return d.numericPolicy != NumericExact
+		default:
+			return d.numericPolicy == NumericConvert || d.floatNarrowing
+		}
+	}
+
+	// Integers
+	sameSign := isIntKind(sk) == isIntKind(dk)
+	switch d.numericPolicy {
+	case NumericExact:
+		return sameSign && src.Bits() == dst.Bits()
+	case NumericWiden:
+		return (sameSign && dst.Bits() >= src.Bits()) ||
+			(isUintKind(sk) && dst.Bits() > src.Bits())
+	}
+	return true
+}
+
+// Convert a float to an integer if it is integral and fits the destination.
+func floatToInt(dst reflect.Value, f float64) bool {
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return false
+	}
+
+	// Large floats are all integral, so the conversion is checked against
+	// the bounds rather than by converting back.
+	switch k := dst.Kind(); {
+	case isIntKind(k):
+		if f < math.MinInt64 || f >= math.MaxInt64 || dst.OverflowInt(int64(f)) {
+			return false
+		}
+		dst.SetInt(int64(f))
+	case isUintKind(k):
+		if f < 0 || f >= math.MaxUint64 || dst.OverflowUint(uint64(f)) {
+			return false
+		}
+		dst.SetUint(uint64(f))
+	default:
+		return false
+	}
+	return true
+}
+
+// Convert a float vector to an integer slice of the destination type.
+// Returns nil if any element is not integral or does not fit.
+func floatIntVectorConv(dst reflect.Value, src any) any {
+	srcSlice := reflect.ValueOf(src)
+	dstSlice := reflect.MakeSlice(reflect.SliceOf(dst.Type().Elem()), srcSlice.Len(), srcSlice.Len())
+
+	for i := 0; i < srcSlice.Len(); i++ {
+		if !floatToInt(dstSlice.Index(i), srcSlice.Index(i).Float()) {
+			return nil
+		}
+	}
+
+	return dstSlice.Interface()
+}
+
+// Convert a numeric vector to a slice or array element type,
+// subject to the numeric policy.
+// Returns nil if the policy or the values don't allow it.
+func (d *decodeState) numericVectorConv(dst reflect.Value, src any) any {
+	srcType := reflect.TypeOf(src)
+	if srcType.Kind() != reflect.Slice {
+		return vectorConv(dst, src)
+	}
+
+	// Complex numbers are made of float pairs, so the policy applies to
+	// the parts, both for a single complex value and for a slice
+	if part := complexPart(dst.Type()); part != nil {
+		if isFloatKind(srcType.Elem().Kind()) && !d.numericAllowed(srcType.Elem(), part) {
+			return nil
+		}
+		return vectorConv(dst, src)
+	}
+
+	if dst.Kind() != reflect.Slice {
+		return vectorConv(dst, src)
+	}
+
+	dstElem := dst.Type().Elem()
+	srcElem := srcType.Elem()
+
+	dk, sk := dstElem.Kind(), srcElem.Kind()
+	numeric := func(k reflect.Kind) bool {
+		return isIntKind(k) || isUintKind(k) || isFloatKind(k)
+	}
+
+	if !numeric(dk) || !numeric(sk) {
+		return vectorConv(dst, src)
+	}
+
+	if !d.numericAllowed(srcElem, dstElem) {
+		return nil
+	}
+
+	if isFloatKind(sk) && !isFloatKind(dk) {
+		return floatIntVectorConv(dst, src)
+	}
+
+	return vectorConv(dst, src)
+}
+
+// The float type of the parts of a complex type, or of the elements of
+// a complex slice. Returns nil for other types.
+func complexPart(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Complex64:
+		return numericTypes[F32]
+	case reflect.Complex128:
+		return numericTypes[F64]
+	}
+	return nil
+}
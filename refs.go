@@ -0,0 +1,220 @@
+package ltvgo
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Shared pointers (MarshalOptions.References)
+//
+// A pointer reached more than once while encoding is written in full the
+// first time, wrapped in a struct with a numeric id:
+//
+//	{"$id": U32, "$value": value}
+//
+// and each later occurrence is written as a reference to that id:
+//
+//	{"$ref": U32}
+//
+// Pointers reached only once are written as usual. Ids are numbered from
+// zero in the order they are written, so a reference always follows the
+// value it refers to, possibly from within it for a cycle.
+// UnmarshalOptions.References decodes the convention back into shared
+// pointers. Only pointers written by the default pointer encoding take
+// part, not those of types with their own Marshaler or codec.
+const (
+	IDKey  = "$id"
+	RefKey = "$ref"
+)
+
+// Pointer identity: a pointer to a struct and to its first field are
+// distinguished by type.
+type refKey struct {
+	ptr unsafe.Pointer
+	typ reflect.Type
+}
+
+// The pointer sharing state of an encodeState.
+type refState struct {
+	counting bool              // First pass, counting pointers
+	count    map[refKey]int    // Times each pointer was reached
+	ids      map[refKey]uint32 // Ids of the shared pointers written so far
+}
+
+// Encode v with shared pointers. The value is traversed twice: first to
+// find the pointers that are reached more than once, then to write it.
+func (e *encodeState) referencedValue(v reflect.Value, opts encOpts) {
+	e.refs = &refState{
+		counting: true,
+		count:    make(map[refKey]int),
+		ids:      make(map[refKey]uint32),
+	}
+	defer func() { e.refs = nil }()
+
+	var c CountingEncoder
+	alt := e.alt
+	e.alt = &c
+	e.reflectValue(v, opts)
+	e.alt = alt
+
+	e.refs.counting = false
+	e.reflectValue(v, opts)
+}
+
+// Whether this is the counting pass of References. Marshalers and codecs
+// are skipped by it, as their values don't take part, so that they are
+// only called once.
+func (e *encodeState) countingRefs() bool {
+	return e.refs != nil && e.refs.counting
+}
+
+// Write a pointer as a reference or an identified value where it is shared.
+// Returns false if the pointer should be written as usual.
+func (e *encodeState) refPointer(v reflect.Value, elemEnc encoderFunc, opts encOpts) bool {
+	k := refKey{v.UnsafePointer(), v.Type()}
+
+	if e.refs.counting {
+		e.refs.count[k]++
+		if e.refs.count[k] > 1 {
+			// Don't descend again, which also breaks cycles
+			e.out().WriteNil()
+			return true
+		}
+		return false
+	}
+
+	if id, ok := e.refs.ids[k]; ok {
+		e.out().WriteStructStart()
+		e.out().WriteString(RefKey)
+		e.out().WriteU32(id)
+		e.out().WriteStructEnd()
+		return true
+	}
+
+	if e.refs.count[k] < 2 {
+		return false
+	}
+
+	id := uint32(len(e.refs.ids))
+	e.refs.ids[k] = id
+
+	e.out().WriteStructStart()
+	e.out().WriteString(IDKey)
+	e.out().WriteU32(id)
+	e.out().WriteString(ValueKey)
+	elemEnc(e, v.Elem(), opts)
+	e.out().WriteStructEnd()
+	return true
+}
+
+// Check whether the struct under the decoder starts with an id or a
+// reference, returning the key and the id. If so, the decoder is left
+// positioned after the id. Otherwise it is left at the start of the struct.
+func (d *decodeState) peekRef() (string, uint32, bool) {
+	m := d.decoder.mark()
+
+	desc, err := d.decoder.Next()
+	if err != nil || desc.TypeCode != String {
+		d.decoder.restore(m)
+		return "", 0, false
+	}
+
+	key, err := d.decoder.ReadValue(desc)
+	if err != nil || (key.(string) != IDKey && key.(string) != RefKey) {
+		d.decoder.restore(m)
+		return "", 0, false
+	}
+
+	desc, err = d.decoder.Next()
+	if err != nil || desc.SizeCode != SizeSingle {
+		d.decoder.restore(m)
+		return "", 0, false
+	}
+
+	value, err := d.decoder.ReadValue(desc)
+	id, ok := toInt64(value)
+	if err != nil || !ok || id < 0 || id > int64(^uint32(0)) {
+		d.decoder.restore(m)
+		return "", 0, false
+	}
+
+	return key.(string), uint32(id), true
+}
+
+// Decode an identified value or a reference into the pointer v.
+// Returns false, having consumed nothing, if the struct is neither.
+func (d *decodeState) refValue(desc LtvDesc, v reflect.Value) (bool, error) {
+	key, id, ok := d.peekRef()
+	if !ok {
+		return false, nil
+	}
+
+	// Find the last pointer, as a shared value is only pointed to once
+	for v.Type().Elem().Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if key == RefKey {
+		p, ok := d.refs[id]
+		switch {
+		case !ok:
+			d.saveError(fmt.Errorf("ltv: reference to unknown id %d", id))
+		case !p.Type().AssignableTo(v.Type()) || !v.CanSet():
+			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		default:
+			v.Set(p)
+		}
+		return true, d.skipStructRemainder()
+	}
+
+	if _, ok := d.refs[id]; ok {
+		d.saveError(fmt.Errorf("ltv: duplicate id %d", id))
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	if d.refs == nil {
+		d.refs = make(map[uint32]reflect.Value)
+	}
+
+	// Keep the pointer itself, as v may be moved, e.g. by a growing slice
+	p := reflect.New(v.Type()).Elem()
+	p.Set(v)
+	d.refs[id] = p
+
+	for {
+		kdesc, err := d.decoder.Next()
+		if err != nil {
+			return true, err
+		}
+
+		if kdesc.TypeCode == End {
+			return true, nil
+		}
+
+		key, err := d.decoder.ReadValue(kdesc)
+		if err != nil {
+			return true, err
+		}
+
+		vdesc, err := d.decoder.Next()
+		if err != nil {
+			return true, err
+		}
+
+		if key.(string) != ValueKey {
+			d.saveError(fmt.Errorf("ltv: unexpected key %q in identified value", key))
+			d.skip(vdesc)
+			continue
+		}
+
+		if err := d.value(vdesc, v.Elem()); err != nil {
+			return true, err
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package ltvgo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Interface values holding a registered type are encoded as a struct
+// naming the type:
+//
+//	{"$type": name, "$value": value}
+//
+// When unmarshaling into an interface, this struct is decoded back into
+// a value of the registered type. A struct naming an unregistered type is
+// decoded as an ordinary struct into an empty interface, unless
+// UnmarshalOptions.RequireRegisteredTypes is set.
+const (
+	TypeKey  = "$type"
+	ValueKey = "$value"
+)
+
+var (
+	registeredNames sync.Map // map[reflect.Type]string
+	registeredTypes sync.Map // map[string]reflect.Type
+)
+
+// An UnregisteredTypeError is returned by Unmarshal when decoding a type
+// name that has not been registered with RegisterType.
+type UnregisteredTypeError struct {
+	Name string
+}
+
+func (e *UnregisteredTypeError) Error() string {
+	return "ltv: unregistered type name: " + e.Name
+}
+
+// RegisterType records a concrete type under a name, so that interface
+// values holding it round trip through Marshal and Unmarshal.
+//
+// The type of the prototype is registered exactly: value and pointer
+// types are distinct, so register &T{} if interfaces hold *T.
+// RegisterType panics if the name or type is already registered.
+func RegisterType(name string, prototype any) {
+	t := reflect.TypeOf(prototype)
+	if name == "" || t == nil {
+		panic("ltv: RegisterType requires a name and a non-nil prototype")
+	}
+
+	if prev, loaded := registeredTypes.LoadOrStore(name, t); loaded && prev != t {
+		panic(fmt.Sprintf("ltv: registering duplicate types for %q: %s != %s", name, prev, t))
+	}
+	if prev, loaded := registeredNames.LoadOrStore(t, name); loaded && prev != name {
+		panic(fmt.Sprintf("ltv: registering duplicate names for %s: %q != %q", t, prev, name))
+	}
+}
+
+// The registered name of a type, if any.
+func registeredName(t reflect.Type) (string, bool) {
+	name, ok := registeredNames.Load(t)
+	if !ok {
+		return "", false
+	}
+	return name.(string), true
+}
+
+// The type registered under a name, if any.
+func registeredType(name string) (reflect.Type, bool) {
+	t, ok := registeredTypes.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return t.(reflect.Type), true
+}
+
+// Check whether the struct under the decoder starts with a type name which
+// is registered, or any type name if strict. If so, the decoder is left
+// positioned after the name. Otherwise it is left at the start of the struct.
+func (d *decodeState) peekTypeName(strict bool) (string, bool) {
+	m := d.decoder.mark()
+
+	desc, err := d.decoder.Next()
+	if err != nil || desc.TypeCode != String {
+		d.decoder.restore(m)
+		return "", false
+	}
+
+	key, err := d.decoder.ReadValue(desc)
+	if err != nil || key.(string) != TypeKey {
+		d.decoder.restore(m)
+		return "", false
+	}
+
+	desc, err = d.decoder.Next()
+	if err != nil || desc.TypeCode != String {
+		d.decoder.restore(m)
+		return "", false
+	}
+
+	name, err := d.decoder.ReadValue(desc)
+	if err != nil {
+		d.decoder.restore(m)
+		return "", false
+	}
+
+	if _, ok := registeredType(name.(string)); !ok && !strict {
+		d.decoder.restore(m)
+		return "", false
+	}
+
+	return name.(string), true
+}
+
+// Decode the remainder of a {"$type", "$value"} struct into an interface.
+func (d *decodeState) typedValue(desc LtvDesc, name string, v reflect.Value) error {
+	t, ok := registeredType(name)
+	if !ok {
+		d.saveError(&UnregisteredTypeError{Name: name})
+		return d.skipStructRemainder()
+	}
+
+	if !t.AssignableTo(v.Type()) {
+		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		return d.skipStructRemainder()
+	}
+
+	value := reflect.New(t).Elem()
+	seenValue := false
+
+	for {
+		kdesc, err := d.decoder.Next()
+		if err != nil {
+			return err
+		}
+
+		if kdesc.TypeCode == End {
+			break
+		}
+
+		key, err := d.decoder.ReadValue(kdesc)
+		if err != nil {
+			return err
+		}
+
+		vdesc, err := d.decoder.Next()
+		if err != nil {
+			return err
+		}
+
+		if key.(string) != ValueKey || seenValue {
+			d.saveError(fmt.Errorf("ltv: unexpected key %q in typed value", key))
+			d.skip(vdesc)
+			continue
+		}
+
+		seenValue = true
+		if err := d.value(vdesc, value); err != nil {
+			return err
+		}
+	}
+
+	v.Set(value)
+	return nil
+}
+
+// Skip the remaining fields of a struct.
+func (d *decodeState) skipStructRemainder() error {
+	for {
+		desc, err := d.decoder.Next()
+		if err != nil {
+			return err
+		}
+
+		if desc.TypeCode == End {
+			return nil
+		}
+
+		if err := d.decoder.Skip(desc); err != nil {
+			return err
+		}
+	}
+}
+
+// Decode a value into an empty interface as ReadValue does, but resolving
+// registered type names in nested structs as well.
+func (d *decodeState) interfaceValue(desc LtvDesc) (any, error) {
+	switch desc.TypeCode {
+	case Struct:
+		if name, ok := d.peekTypeName(d.requireRegistered); ok {
+			var value any
+			err := d.typedValue(desc, name, reflect.ValueOf(&value).Elem())
+			return value, err
+		}
+		return d.interfaceStruct()
+	case List:
+		return d.interfaceList()
+	}
+	return d.decoder.ReadValue(desc)
+}
+
+// Read the remainder of a struct as a generic map[string]any.
+func (d *decodeState) interfaceStruct() (map[string]any, error) {
+	m := make(map[string]any)
+
+	for {
+		desc, err := d.decoder.Next()
+		if err != nil {
+			return m, err
+		}
+
+		if desc.TypeCode == End {
+			break
+		}
+
+		key, err := d.decoder.ReadValue(desc)
+		if err != nil {
+			return m, err
+		}
+
+		desc, err = d.decoder.Next()
+		if err != nil {
+			return m, err
+		}
+
+		value, err := d.interfaceValue(desc)
+		if err != nil {
+			return m, err
+		}
+
+		m[key.(string)] = value
+	}
+
+	return m, nil
+}
+
+// Read the remainder of a list as a generic []any.
+func (d *decodeState) interfaceList() ([]any, error) {
+	l := make([]any, 0)
+
+	for {
+		desc, err := d.decoder.Next()
+		if err != nil {
+			return l, err
+		}
+
+		if desc.TypeCode == End {
+			break
+		}
+
+		value, err := d.interfaceValue(desc)
+		if err != nil {
+			return l, err
+		}
+
+		l = append(l, value)
+	}
+
+	return l, nil
+}
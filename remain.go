@@ -0,0 +1,182 @@
+package ltvgo
+
+import (
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Catch-all fields for unrecognized keys.
+//
+// A struct field of type map[string]any or map[string][]byte tagged with
+// the "remain" (or "inline") option, for example:
+//
+//	Extra map[string]any `ltv:",remain"`
+//
+// collects the keys that Unmarshal finds no field for, and Marshal writes
+// them back after the known fields. A map[string][]byte holds the encoded
+// values as they were read, which are written back element by element, so
+// vectors are aligned for their new position.
+// Keys collected by a remain field are not reported as unknown fields.
+
+// Check whether a type can hold the unrecognized keys of a struct.
+func isRemainType(t reflect.Type) bool {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return false
+	}
+
+	elem := t.Elem()
+	switch elem.Kind() {
+	case reflect.Interface:
+		return elem.NumMethod() == 0
+	case reflect.Slice:
+		return elem.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// Write the keys of a remain field, skipping any which would repeat a known field.
+func (e *encodeState) remain(v reflect.Value, known map[string]int, opts encOpts) {
+	if v.IsNil() {
+		return
+	}
+
+	keys := make([]string, 0, v.Len())
+	mi := v.MapRange()
+	for mi.Next() {
+		if _, ok := known[mi.Key().String()]; !ok {
+			keys = append(keys, mi.Key().String())
+		}
+	}
+	sort.Strings(keys)
+
+	raw := v.Type().Elem().Kind() == reflect.Slice
+	for _, k := range keys {
+		e.out().WriteString(k)
+
+		value := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+		if !raw {
+			e.reflectValue(value, opts)
+			continue
+		}
+
+		b := value.Bytes()
+		if err := Validate(b); err != nil {
+			e.error(&MarshalerError{v.Type(), err, "remain"})
+		}
+		e.rawValue(b)
+	}
+}
+
+// Write a valid encoded value through the encoder. Copying the bytes
+// as they are would keep the alignment padding of their old position.
+func (e *encodeState) rawValue(b []byte) {
+	d := NewDecoder(b)
+	for {
+		desc, err := d.Next()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			e.error(err)
+		}
+
+		switch desc.TypeCode {
+		case Nil:
+			e.out().WriteNil()
+			continue
+		case Struct:
+			e.out().WriteStructStart()
+			continue
+		case List:
+			e.out().WriteListStart()
+			continue
+		case End:
+			e.out().WriteStructEnd()
+			continue
+		}
+
+		val, err := d.ReadValue(desc)
+		if err != nil {
+			e.error(err)
+		}
+
+		switch val := val.(type) {
+		case string:
+			e.out().WriteString(val)
+		case bool:
+			e.out().WriteBool(val)
+		case uint8:
+			e.out().WriteU8(val)
+		case uint16:
+			e.out().WriteU16(val)
+		case uint32:
+			e.out().WriteU32(val)
+		case uint64:
+			e.out().WriteU64(val)
+		case int8:
+			e.out().WriteI8(val)
+		case int16:
+			e.out().WriteI16(val)
+		case int32:
+			e.out().WriteI32(val)
+		case int64:
+			e.out().WriteI64(val)
+		case float32:
+			e.out().WriteF32(val)
+		case float64:
+			e.out().WriteF64(val)
+		case []bool:
+			e.out().WriteBoolVec(val)
+		case []uint8:
+			e.out().WriteU8Vec(val)
+		case []uint16:
+			e.out().WriteU16Vec(val)
+		case []uint32:
+			e.out().WriteU32Vec(val)
+		case []uint64:
+			e.out().WriteU64Vec(val)
+		case []int8:
+			e.out().WriteI8Vec(val)
+		case []int16:
+			e.out().WriteI16Vec(val)
+		case []int32:
+			e.out().WriteI32Vec(val)
+		case []int64:
+			e.out().WriteI64Vec(val)
+		case []float32:
+			e.out().WriteF32Vec(val)
+		case []float64:
+			e.out().WriteF64Vec(val)
+		}
+	}
+}
+
+// Store an unrecognized key and its value in the remain field of struct v.
+func (d *decodeState) remainValue(desc LtvDesc, v reflect.Value, rf *field, key string) error {
+	mv, ok := fieldByIndexAlloc(v, rf.index)
+	if !ok {
+		d.skip(desc)
+		return nil
+	}
+
+	if mv.IsNil() {
+		mv.Set(reflect.MakeMap(mv.Type()))
+	}
+
+	kv := reflect.ValueOf(key).Convert(mv.Type().Key())
+	elem := reflect.New(mv.Type().Elem()).Elem()
+
+	if elem.Kind() == reflect.Slice {
+		// Keep the encoded value
+		start := desc.Offset
+		if err := d.decoder.Skip(desc); err != nil {
+			return err
+		}
+		elem.SetBytes(append([]byte(nil), d.decoder.buf[start:d.decoder.pos]...))
+	} else if err := d.value(desc, elem); err != nil {
+		return err
+	}
+
+	mv.SetMapIndex(kv, elem)
+	return nil
+}
@@ -0,0 +1,192 @@
+package ltvgo
+
+import (
+	"context"
+	"reflect"
+)
+
+// Channels and iterator functions
+//
+// Values of a receive channel type, or of an iterator function type:
+//
+//	func(yield func(T) bool)
+//	func(yield func(T, error) bool)
+//
+// are encoded as lists of their elements. A channel is read until it is
+// closed, and an iterator until it returns. An iterator yielding a non-nil
+// error stops the encoding with a MarshalerError wrapping it, for sources
+// such as database cursors which may fail part way. An iterator is always
+// stopped by yield returning false, including when an element can't be
+// encoded or written, and the error is reported once it has returned.
+//
+// Each element is written as it is produced, so a StreamEncoder emits a
+// large sequence incrementally rather than materializing it first. With
+// StreamEncoder.EncodeContext, cancelling the context stops the encoding
+// with the context's error. The list written so far is left unterminated.
+//
+// Channels and iterators are consumed by encoding, so they can't be used
+// with MarshalOptions.References, which traverses the value twice.
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Check whether a type is an iterator function.
+func isIterFunc(t reflect.Type) bool {
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+
+	yield := t.In(0)
+	if yield.Kind() != reflect.Func || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+		return false
+	}
+
+	switch yield.NumIn() {
+	case 1:
+		return true
+	case 2:
+		return yield.In(1) == errorType
+	}
+	return false
+}
+
+// The error of a cancelled context, if any.
+func (opts encOpts) ctxErr() error {
+	if opts.ctx == nil {
+		return nil
+	}
+	return opts.ctx.Err()
+}
+
+// Stop reading a sequence once the stream can't be written.
+func (e *encodeState) checkWrite() {
+	if s, ok := e.alt.(*StreamEncoder); ok && s.Werr != nil {
+		e.error(s.Werr)
+	}
+}
+
+// Sequences can only be traversed once.
+func (e *encodeState) checkSequence(v reflect.Value) {
+	if e.refs != nil {
+		e.error(&UnsupportedValueError{v, "cannot encode " + v.Type().String() + " with References"})
+	}
+	if _, ok := e.alt.(*CountingEncoder); ok {
+		e.error(&UnsupportedValueError{v, "cannot size " + v.Type().String() + " without reading it"})
+	}
+}
+
+type chanEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (ce chanEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.out().WriteNil()
+		return
+	}
+	e.checkSequence(v)
+
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: v}}
+	if opts.ctx != nil && opts.ctx.Done() != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(opts.ctx.Done())})
+	}
+
+	e.out().WriteListStart()
+	for {
+		chosen, elem, ok := reflect.Select(cases)
+		if chosen == 1 {
+			e.error(opts.ctx.Err())
+		}
+		if !ok {
+			break
+		}
+		ce.elemEnc(e, elem, opts)
+		e.checkWrite()
+	}
+	e.out().WriteListEnd()
+}
+
+func newChanEncoder(t reflect.Type) encoderFunc {
+	if t.ChanDir()&reflect.RecvDir == 0 {
+		return unsupportedTypeEncoder
+	}
+	enc := chanEncoder{typeEncoder(t.Elem())}
+	return enc.encode
+}
+
+type iterEncoder struct {
+	elemEnc encoderFunc
+	yield   reflect.Type
+}
+
+func (ie iterEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.out().WriteNil()
+		return
+	}
+	e.checkSequence(v)
+
+	var err error
+	yield := reflect.MakeFunc(ie.yield, func(args []reflect.Value) []reflect.Value {
+		switch {
+		case err != nil:
+		case len(args) > 1 && !args[1].IsNil():
+			err = &MarshalerError{v.Type(), args[1].Interface().(error), "iterator"}
+		default:
+			if err = opts.ctxErr(); err == nil {
+				err = ie.element(e, args[0], opts)
+			}
+		}
+		return []reflect.Value{reflect.ValueOf(err == nil)}
+	})
+
+	e.out().WriteListStart()
+	v.Call([]reflect.Value{yield})
+	if err != nil {
+		e.error(err)
+	}
+	e.out().WriteListEnd()
+}
+
+// Encode an element produced by an iterator. Errors are returned rather than
+// unwinding through the iterator, which is stopped by yield returning false
+// so that it can clean up.
+func (ie iterEncoder) element(e *encodeState, v reflect.Value, opts encOpts) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if le, ok := r.(ltvError); ok {
+				err = le.error
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	ie.elemEnc(e, v, opts)
+	e.checkWrite()
+	return nil
+}
+
+func newIterEncoder(t reflect.Type) encoderFunc {
+	enc := iterEncoder{typeEncoder(t.In(0).In(0)), t.In(0)}
+	return enc.encode
+}
+
+// EncodeContext writes the LiteVector encoding of v to the stream, as
+// Encode does. Channels and iterators in v stop being read with the
+// context's error when it is cancelled.
+func (s *StreamEncoder) EncodeContext(ctx context.Context, v any) error {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+
+	opts := s.Options.encOpts()
+	opts.ctx = ctx
+
+	e.alt = s
+	err := e.marshal(v, opts)
+	e.alt = nil
+	if err != nil {
+		return err
+	}
+
+	return s.Werr
+}
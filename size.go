@@ -0,0 +1,168 @@
+package ltvgo
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// A CountingEncoder is an LtvEncoder which writes nothing, and only counts
+// the bytes an Encoder would have written, alignment padding included.
+// Padding depends on the offset of each vector, so a count is exact for
+// data written at the start of a buffer, or after SetOffset.
+type CountingEncoder struct {
+	n int
+}
+
+// Len returns the number of bytes counted.
+func (e *CountingEncoder) Len() int {
+	return e.n
+}
+
+func (e *CountingEncoder) Reset() {
+	e.n = 0
+}
+
+// Manually set the offset the count starts from.
+func (e *CountingEncoder) SetOffset(offset int) {
+	e.n = offset
+}
+
+func (e *CountingEncoder) RawWrite(b []byte)     { e.n += len(b) }
+func (e *CountingEncoder) RawWriteByte(byte)     { e.n++ }
+func (e *CountingEncoder) RawWriteUint16(uint16) { e.n += 2 }
+func (e *CountingEncoder) RawWriteUint32(uint32) { e.n += 4 }
+func (e *CountingEncoder) RawWriteUint64(uint64) { e.n += 8 }
+
+func (e *CountingEncoder) WriteTag(TypeCode, SizeCode) { e.n++ }
+
+// Count the alignment padding, tag and length for a typed vector, as
+// written by Encoder.WriteVectorPrefix.
+func (e *CountingEncoder) WriteVectorPrefix(t TypeCode, count int) {
+	typeSize := typeSizes[t]
+	bufLen := uint64(typeSize * count)
+
+	var lenSize int
+	switch {
+	case bufLen <= math.MaxUint8:
+		lenSize = 1
+	case bufLen <= math.MaxUint16:
+		lenSize = 2
+	case bufLen <= math.MaxUint32:
+		lenSize = 4
+	default:
+		lenSize = 8
+	}
+
+	if alignmentDelta := (e.n + 1 + lenSize) & (typeSize - 1); alignmentDelta != 0 {
+		e.n += typeSize - alignmentDelta
+	}
+	e.n += 1 + lenSize
+}
+
+// Count a complete vector of count elements.
+func (e *CountingEncoder) vector(t TypeCode, count int) {
+	e.WriteVectorPrefix(t, count)
+	e.n += count * typeSizes[t]
+}
+
+func (e *CountingEncoder) WriteNop()         { e.n++ }
+func (e *CountingEncoder) WriteNil()         { e.n++ }
+func (e *CountingEncoder) WriteStructStart() { e.n++ }
+func (e *CountingEncoder) WriteStructEnd()   { e.n++ }
+func (e *CountingEncoder) WriteListStart()   { e.n++ }
+func (e *CountingEncoder) WriteListEnd()     { e.n++ }
+
+func (e *CountingEncoder) WriteBool(bool)   { e.n += 2 }
+func (e *CountingEncoder) WriteU8(uint8)    { e.n += 2 }
+func (e *CountingEncoder) WriteU16(uint16)  { e.n += 3 }
+func (e *CountingEncoder) WriteU32(uint32)  { e.n += 5 }
+func (e *CountingEncoder) WriteU64(uint64)  { e.n += 9 }
+func (e *CountingEncoder) WriteI8(int8)     { e.n += 2 }
+func (e *CountingEncoder) WriteI16(int16)   { e.n += 3 }
+func (e *CountingEncoder) WriteI32(int32)   { e.n += 5 }
+func (e *CountingEncoder) WriteI64(int64)   { e.n += 9 }
+func (e *CountingEncoder) WriteF32(float32) { e.n += 5 }
+func (e *CountingEncoder) WriteF64(float64) { e.n += 9 }
+
+// Count an int with Goldilocks fitting
+func (e *CountingEncoder) WriteInt(v int64) {
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		e.n += 2
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		e.n += 3
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		e.n += 5
+	default:
+		e.n += 9
+	}
+}
+
+// Count a uint with Goldilocks fitting
+func (e *CountingEncoder) WriteUint(v uint64) {
+	switch {
+	case v <= math.MaxUint8:
+		e.n += 2
+	case v <= math.MaxUint16:
+		e.n += 3
+	case v <= math.MaxUint32:
+		e.n += 5
+	default:
+		e.n += 9
+	}
+}
+
+func (e *CountingEncoder) WriteString(s string) {
+	if !utf8.ValidString(s) {
+		panic("ltv: WriteString requires a valid UTF-8 string")
+	}
+
+	if len(s) == 1 {
+		e.n += 2
+	} else {
+		e.vector(String, len(s))
+	}
+}
+
+func (e *CountingEncoder) WriteBytes(v []byte)     { e.vector(U8, len(v)) }
+func (e *CountingEncoder) WriteBoolVec(v []bool)   { e.vector(Bool, len(v)) }
+func (e *CountingEncoder) WriteU8Vec(v []uint8)    { e.vector(U8, len(v)) }
+func (e *CountingEncoder) WriteU16Vec(v []uint16)  { e.vector(U16, len(v)) }
+func (e *CountingEncoder) WriteU32Vec(v []uint32)  { e.vector(U32, len(v)) }
+func (e *CountingEncoder) WriteU64Vec(v []uint64)  { e.vector(U64, len(v)) }
+func (e *CountingEncoder) WriteI8Vec(v []int8)     { e.vector(I8, len(v)) }
+func (e *CountingEncoder) WriteI16Vec(v []int16)   { e.vector(I16, len(v)) }
+func (e *CountingEncoder) WriteI32Vec(v []int32)   { e.vector(I32, len(v)) }
+func (e *CountingEncoder) WriteI64Vec(v []int64)   { e.vector(I64, len(v)) }
+func (e *CountingEncoder) WriteF32Vec(v []float32) { e.vector(F32, len(v)) }
+func (e *CountingEncoder) WriteF64Vec(v []float64) { e.vector(F64, len(v)) }
+func (e *CountingEncoder) WriteIntVec(v []int64)   { e.vector(fitIntVec(v), len(v)) }
+func (e *CountingEncoder) WriteUintVec(v []uint64) { e.vector(fitUintVec(v), len(v)) }
+
+// Size returns the length of the encoding of v by Marshal, without
+// encoding it.
+func Size(v any) (int, error) {
+	return MarshalOptions{}.Size(v)
+}
+
+// Size returns the length of the encoding of v by Marshal with the given
+// options, without encoding it. Marshalers in v are still called, and v
+// may not contain channels or iterators, which can only be read once.
+//
+// Marshal doesn't size its output ahead, which would run marshalers twice.
+// To encode into a buffer of the exact size, pass the size to
+// NewFixedEncoder and use MarshalTo.
+func (o MarshalOptions) Size(v any) (int, error) {
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+
+	var c CountingEncoder
+	e.alt = &c
+	err := e.marshal(v, o.encOpts())
+	e.alt = nil
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Len(), nil
+}
@@ -1,6 +1,7 @@
 package ltvgo
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io"
 	"math"
@@ -121,8 +122,18 @@ type StreamDecoder struct {
 	// If set to true, will return NOP tags.
 	ReturnNops bool
 
-	// The maximum length supported by the ReadValue function
+	// The maximum length supported by the ReadValue function, and of a
+	// whole value read by Decode
 	MaxValueLength uint64
+
+	// Options used by Decode
+	Options UnmarshalOptions
+
+	// An element read ahead by More, and its encoded bytes
+	peeked    bool
+	peekDesc  LtvElementDesc
+	peekErr   error
+	peekBytes []byte
 }
 
 func NewStreamDecoder(r io.Reader) *StreamDecoder {
@@ -135,6 +146,18 @@ func NewStreamDecoder(r io.Reader) *StreamDecoder {
 	}
 }
 
+// Reset the decoder to read from r, keeping its options and reusing its
+// memory.
+func (s *StreamDecoder) Reset(r io.Reader) {
+	s.r = r
+	s.offset = 0
+	s.tracker.stack = s.tracker.stack[:0]
+	s.peeked = false
+	s.peekDesc = LtvElementDesc{}
+	s.peekErr = nil
+	s.peekBytes = nil
+}
+
 // Read a byte from the underlying stream and return the byte or error.
 func (s *StreamDecoder) ReadByte() (byte, error) {
 	var buf [1]byte
@@ -164,6 +187,76 @@ func (s *StreamDecoder) ReadFull(buf []byte) error {
 // Read the next tag or tag and length prefix from the stream.
 // On return, the scanner will be positioned over the value.
 func (s *StreamDecoder) Next() (LtvElementDesc, error) {
+	if s.peeked {
+		s.peeked = false
+		return s.peekDesc, s.peekErr
+	}
+	return s.next()
+}
+
+// Read the next element ahead, keeping the bytes read for Decode.
+func (s *StreamDecoder) peek() (LtvElementDesc, error) {
+	if !s.peeked {
+		var buf bytes.Buffer
+		r := s.r
+		s.r = io.TeeReader(r, &buf)
+		s.peekDesc, s.peekErr = s.next()
+		s.r = r
+
+		s.peeked = true
+		s.peekBytes = buf.Bytes()
+	}
+	return s.peekDesc, s.peekErr
+}
+
+// More reports whether there is another element in the current list or
+// struct, or at the top level, another value in the stream.
+func (s *StreamDecoder) More() bool {
+	desc, err := s.peek()
+	for err == nil && s.ReturnNops && desc.Tag == NopTag {
+		s.peeked = false
+		desc, err = s.peek()
+	}
+	return err == nil && desc.TypeCode != End
+}
+
+// A Delim is a list or struct delimiter returned by Token:
+// '[' or ']' for a list, '{' or '}' for a struct.
+type Delim rune
+
+func (d Delim) String() string {
+	return string(d)
+}
+
+// Token returns the next element in the stream: a Delim for the start or
+// end of a list or struct, or the value of any other element, as read by
+// ReadValue. Struct keys are returned as strings. Token may be mixed with
+// Decode to decode the elements of a list or the values of a struct.
+func (s *StreamDecoder) Token() (any, error) {
+	desc, err := s.Next()
+	for err == nil && s.ReturnNops && desc.Tag == NopTag {
+		desc, err = s.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch desc.TypeCode {
+	case List:
+		return Delim('['), nil
+	case Struct:
+		return Delim('{'), nil
+	case End:
+		if desc.Role == RoleStructEnd {
+			return Delim('}'), nil
+		}
+		return Delim(']'), nil
+	}
+
+	return s.ReadValue(desc)
+}
+
+func (s *StreamDecoder) next() (LtvElementDesc, error) {
 	var buf [8]byte
 	var d LtvElementDesc
 	d.TagOffset = s.offset
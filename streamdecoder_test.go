@@ -0,0 +1,171 @@
+package ltvgo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestVectorReader(t *testing.T) {
+	wave := make([]float32, 10000)
+	for i := range wave {
+		wave[i] = float32(i) / 3
+	}
+
+	e := NewEncoder()
+	e.WriteListStart()
+	e.WriteF32Vec(wave)
+	e.WriteString("some text")
+	e.WriteI16Vec([]int16{-1, 2, -3})
+	e.WriteListEnd()
+
+	d := NewStreamDecoder(bytes.NewReader(e.Bytes()))
+	d.MaxValueLength = 100
+
+	if _, err := d.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Floats, through a small buffer
+	desc, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewVectorReader(d, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Type() != F32 || r.Remaining() != len(wave) {
+		t.Fatalf("unexpected vector: %v %d", r.Type(), r.Remaining())
+	}
+
+	if _, err := r.ReadI16(make([]int16, 1)); err == nil {
+		t.Fatal("expected a type error")
+	}
+
+	var got []float32
+	buf := make([]float32, 333)
+	for {
+		n, err := r.ReadF32(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != len(wave) {
+		t.Fatalf("read %d elements", len(got))
+	}
+	for i := range wave {
+		if got[i] != wave[i] {
+			t.Fatalf("element %d: %v != %v", i, got[i], wave[i])
+		}
+	}
+
+	// Strings as an io.Reader
+	desc, err = d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewVectorReader(d, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(r)
+	if err != nil || string(text) != "some text" {
+		t.Fatalf("unexpected text: %q %v", text, err)
+	}
+
+	// Skipping part of a vector
+	desc, err = d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = NewVectorReader(d, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i16 := make([]int16, 1)
+	if n, err := r.ReadI16(i16); n != 1 || err != nil || i16[0] != -1 {
+		t.Fatalf("unexpected read: %d %v %v", n, err, i16)
+	}
+	if err := r.Skip(); err != nil {
+		t.Fatal(err)
+	}
+
+	if desc, err := d.Next(); err != nil || desc.TypeCode != End {
+		t.Fatalf("unexpected element after vectors: %v %v", desc, err)
+	}
+
+	// Not a vector
+	e.Reset()
+	e.WriteU8(1)
+	d = NewStreamDecoder(bytes.NewReader(e.Bytes()))
+	desc, _ = d.Next()
+	if _, err := NewVectorReader(d, desc); err == nil {
+		t.Fatal("expected an error for a single value")
+	}
+
+	// Truncated vectors
+	e.Reset()
+	e.WriteF64Vec([]float64{1, 2, 3})
+	d = NewStreamDecoder(bytes.NewReader(e.Bytes()[:12]))
+	desc, _ = d.Next()
+	r, err = NewVectorReader(d, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadF64(make([]float64, 3)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	e := NewEncoder()
+	e.WriteListStart()
+	e.WriteU8(1)
+	e.WriteU8(2)
+	e.WriteListEnd()
+	list := append([]byte(nil), e.Bytes()...)
+
+	e.Reset()
+	e.WriteString("next")
+	str := e.Bytes()
+
+	// Abandoned inside a list
+	d := NewDecoder(list)
+	d.Next()
+	d.Next()
+	d.Reset(str)
+	desc, err := d.Next()
+	if err != nil || desc.TypeCode != String || desc.Offset != 0 {
+		t.Fatalf("unexpected element: %+v %v", desc, err)
+	}
+	if err := d.ValidateAndSkip(desc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Fatalf("expected EOF: %v", err)
+	}
+
+	// Abandoned inside a list, with an element read ahead
+	sd := NewStreamDecoder(bytes.NewReader(list))
+	sd.MaxValueLength = 16
+	sd.Next()
+	if !sd.More() {
+		t.Fatal("expected more elements")
+	}
+	sd.Reset(bytes.NewReader(str))
+	if sd.MaxValueLength != 16 {
+		t.Fatal("options not kept")
+	}
+	var s string
+	if err := sd.Decode(&s); err != nil || s != "next" {
+		t.Fatalf("unexpected value: %q %v", s, err)
+	}
+	if _, err := sd.Next(); err != io.EOF {
+		t.Fatalf("expected EOF: %v", err)
+	}
+}
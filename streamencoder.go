@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"io"
 	"math"
+	"os"
 	"unicode/utf8"
 )
 
@@ -12,6 +13,17 @@ type StreamEncoder struct {
 	offset  int
 	Werr    error
 	scratch [8]byte
+
+	// Options used by Encode
+	Options MarshalOptions
+
+	// Vectors written with BeginVector beyond this many bytes are spooled
+	// to a temporary file in SpoolDir (or the default temporary directory),
+	// rather than memory. Zero spools all vectors in memory.
+	SpoolMemLimit int
+	SpoolDir      string
+
+	spool *spool // Vector started by BeginVector
 }
 
 func NewStreamEncoder(w io.Writer) *StreamEncoder {
@@ -25,6 +37,13 @@ func NewStreamEncoder(w io.Writer) *StreamEncoder {
 func (e *StreamEncoder) Reset() {
 	e.offset = 0
 	e.Werr = nil
+
+	// Discard any open vector
+	if e.spool != nil && e.spool.file != nil {
+		e.spool.file.Close()
+		os.Remove(e.spool.file.Name())
+	}
+	e.spool = nil
 }
 
 // Manually set the "offset" of the stream encoder.
@@ -236,6 +255,16 @@ func (e *StreamEncoder) WriteUint(v uint64) {
 	}
 }
 
+// Write int vector with Goldilocks fitting
+func (e *StreamEncoder) WriteIntVec(v []int64) {
+	writeIntVec(e, v)
+}
+
+// Write uint vector with Goldilocks fitting
+func (e *StreamEncoder) WriteUintVec(v []uint64) {
+	writeUintVec(e, v)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 func (e *StreamEncoder) WriteString(s string) {
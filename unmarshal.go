@@ -10,8 +10,11 @@
 package ltvgo
 
 import (
+	"bytes"
 	"encoding"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"reflect"
 	"strconv"
@@ -19,11 +22,129 @@ import (
 )
 
 func Unmarshal(data []byte, v any) error {
+	return UnmarshalOptions{}.Unmarshal(data, v)
+}
+
+// UnmarshalOptions configures an Unmarshal call.
+type UnmarshalOptions struct {
+	// Codecs used in preference to those registered with RegisterCodec.
+	Codecs *Codecs
+
+	// Decode native encodings of time.Time, time.Duration, big.Int,
+	// netip.Addr and net.IP, as written by MarshalOptions.WellKnownTypes.
+	WellKnownTypes bool
+
+	// Allow vectors to be decoded into Go arrays of a different length,
+	// dropping extra elements or zeroing the remainder of the array.
+	// By default a length mismatch is an error.
+	TruncateArrays bool
+
+	// Numeric conversions allowed between encoded and Go types.
+	// Violations are reported as an UnmarshalTypeError.
+	NumericPolicy NumericPolicy
+
+	// Allow float64 values to be rounded to float32 under the
+	// NumericWiden and NumericExact policies.
+	AllowFloatNarrowing bool
+
+	// Allow floats to be stored in integer types when they
+	// are integral and in range, under any policy.
+	AllowIntegralFloat bool
+
+	// Match struct keys to field names exactly. By default a key without
+	// an exact match falls back to a case-insensitive match.
+	CaseSensitive bool
+
+	// Report a repeated struct field or map key as a FieldError wrapping
+	// ErrDuplicateKey, rather than keeping the last value.
+	DisallowDuplicateKeys bool
+
+	// Decode the identified values and references written by
+	// MarshalOptions.References into shared pointers.
+	References bool
+
+	// Report a {"$type": name, ...} struct naming an unregistered type as
+	// an UnregisteredTypeError when decoding into an empty interface. By
+	// default it is decoded as an ordinary struct. Interfaces with methods
+	// can only be filled by a registered type, so always report it.
+	RequireRegisteredTypes bool
+}
+
+// Unmarshal data into v with the given options.
+func (o UnmarshalOptions) Unmarshal(data []byte, v any) error {
 	var d decodeState
 	d.init(data)
+	d.codecs = o.Codecs
+	d.wellKnown = o.WellKnownTypes
+	d.truncateArrays = o.TruncateArrays
+	d.numericPolicy = o.NumericPolicy
+	d.floatNarrowing = o.AllowFloatNarrowing
+	d.integralFloats = o.AllowIntegralFloat
+	d.caseSensitive = o.CaseSensitive
+	d.disallowDups = o.DisallowDuplicateKeys
+	d.references = o.References
+	d.requireRegistered = o.RequireRegisteredTypes
 	return d.unmarshal(v)
 }
 
+// Decode reads the next value from the stream and stores it in v,
+// as Unmarshal would with the decoder's Options.
+// The value is read into memory in full before it is decoded, and may
+// be at most MaxValueLength bytes long, containers included. A longer
+// value is an error, even if each of its elements is within the limit.
+func (s *StreamDecoder) Decode(v any) error {
+	t := valueTee{r: s.r, max: s.MaxValueLength}
+
+	// The bytes of an element read ahead by More
+	if s.peeked {
+		t.buf.Write(s.peekBytes)
+	}
+
+	s.r = &t
+	defer func() { s.r = t.r }()
+
+	desc, err := s.Next()
+	for err == nil && s.ReturnNops && desc.Tag == NopTag {
+		desc, err = s.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	if desc.TypeCode == End {
+		return errExpectedValue
+	}
+
+	if err := s.ValidateAndSkip(desc); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	return s.Options.Unmarshal(t.buf.Bytes(), v)
+}
+
+// A valueTee keeps the bytes read from r, up to max of them.
+type valueTee struct {
+	r   io.Reader
+	buf bytes.Buffer
+	max uint64
+}
+
+func (t *valueTee) Read(p []byte) (int, error) {
+	n := uint64(t.buf.Len())
+	if n >= t.max {
+		return 0, errMaxValueLenExceeded
+	}
+	if room := t.max - n; uint64(len(p)) > room {
+		p = p[:room]
+	}
+	m, err := t.r.Read(p)
+	t.buf.Write(p[:m])
+	return m, err
+}
+
 // Unmarshaler is the interface implemented by types
 // that can unmarshal a LiteVector description of themselves.
 // UnmarshalLTV must copy whatever it wishes to retain after returning.
@@ -31,6 +152,14 @@ type Unmarshaler interface {
 	UnmarshalLTV([]byte) error
 }
 
+// UnmarshalerFrom is the interface implemented by types that can read
+// themselves directly from a decoder. It is preferred over Unmarshaler.
+// UnmarshalLTVFrom is given the descriptor of the next value and must
+// consume it completely, for example with Decoder.ReadValue or Decoder.Skip.
+type UnmarshalerFrom interface {
+	UnmarshalLTVFrom(*Decoder, LtvDesc) error
+}
+
 // An UnmarshalTypeError describes a LiteVector value that was
 // not appropriate for a value of a specific Go type.
 type UnmarshalTypeError struct {
@@ -47,6 +176,33 @@ func (e *UnmarshalTypeError) Error() string {
 	return "ltv: cannot unmarshal " + e.Desc.TypeCode.String() + " into Go value of type " + e.GoType.String()
 }
 
+// Errors wrapped by a FieldError.
+var (
+	ErrUnknownField = errors.New("unknown field")
+	ErrDuplicateKey = errors.New("duplicate key")
+	ErrMissingField = errors.New("missing field")
+)
+
+// A FieldError describes a struct or map key rejected by Unmarshal,
+// or a required struct key which is absent.
+type FieldError struct {
+	Err    error        // ErrUnknownField, ErrDuplicateKey or ErrMissingField
+	Key    string       // the key as encoded
+	GoType reflect.Type // Go type of the struct or map holding the key
+	Field  string       // the full path from root node to the struct or map
+}
+
+func (e *FieldError) Error() string {
+	if e.Field != "" {
+		return "ltv: " + e.Err.Error() + " " + strconv.Quote(e.Key) + " in Go struct field " + e.Field + " of type " + e.GoType.String()
+	}
+	return "ltv: " + e.Err.Error() + " " + strconv.Quote(e.Key) + " in Go value of type " + e.GoType.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
 // An InvalidUnmarshalError describes an invalid argument passed to Unmarshal.
 // (The argument to Unmarshal must be a non-nil pointer.)
 type InvalidUnmarshalError struct {
@@ -74,11 +230,24 @@ type decodeState struct {
 	decoder      Decoder
 	errorContext *errorContext
 	savedError   error
+
+	codecs         *Codecs
+	wellKnown      bool
+	truncateArrays bool
+	numericPolicy  NumericPolicy
+	floatNarrowing bool
+	integralFloats bool
+	caseSensitive  bool
+	disallowDups   bool
+	references     bool
+
+	requireRegistered bool
+
+	refs map[uint32]reflect.Value // Pointers by id, with references
 }
 
 func (d *decodeState) init(data []byte) *decodeState {
-	d.decoder = *NewDecoder(data)
-	//d.decoder.Init(data)
+	d.decoder.Reset(data)
 	return d
 }
 
@@ -97,6 +266,8 @@ func (d *decodeState) addErrorContext(err error) error {
 		case *UnmarshalTypeError:
 			err.Struct = d.errorContext.Struct.Name()
 			err.Field = strings.Join(d.errorContext.FieldStack, ".")
+		case *FieldError:
+			err.Field = strings.Join(d.errorContext.FieldStack, ".")
 		}
 	}
 	return err
@@ -130,6 +301,18 @@ func (d *decodeState) skip(desc LtvDesc) {
 }
 
 func (d *decodeState) value(desc LtvDesc, v reflect.Value) error {
+	if v.IsValid() {
+		if ok, err := d.codecValue(desc, v); ok {
+			return err
+		}
+	}
+
+	if d.references && desc.TypeCode == Struct && v.IsValid() && v.Kind() == reflect.Pointer {
+		if ok, err := d.refValue(desc, v); ok {
+			return err
+		}
+	}
+
 	switch desc.TypeCode {
 	case Struct:
 		if v.IsValid() {
@@ -154,6 +337,8 @@ func (d *decodeState) value(desc LtvDesc, v reflect.Value) error {
 			if err := d.storeValue(desc, v); err != nil {
 				return err
 			}
+		} else {
+			d.skip(desc)
 		}
 	}
 
@@ -165,7 +350,12 @@ var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem(
 func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 
 	// Check for unmarshaler.
-	u, _, pv := indirect(v, false)
+	uf, u, _, pv := indirect(v, false)
+
+	// Use UnmarshalLTVFrom
+	if uf != nil {
+		return uf.UnmarshalLTVFrom(&d.decoder, desc)
+	}
 
 	// Use UnmarshalLTV
 	if u != nil {
@@ -178,9 +368,16 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 	v = pv
 	t := v.Type()
 
+	// Decoding a registered type into an interface?
+	if v.Kind() == reflect.Interface {
+		if name, ok := d.peekTypeName(d.requireRegistered || v.NumMethod() > 0); ok {
+			return d.typedValue(desc, name, v)
+		}
+	}
+
 	// Decoding into any interface? Switch to non-reflect code.
 	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
-		value, err := d.decoder.ReadValue(desc)
+		value, err := d.interfaceStruct()
 		if err != nil {
 			return err
 		}
@@ -228,6 +425,18 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 		origErrorContext = *d.errorContext
 	}
 
+	// Keys seen, for duplicate detection
+	var seenKeys map[string]bool
+	var seenFields []bool
+	if d.disallowDups {
+		if v.Kind() == reflect.Map || fields.remain != nil {
+			seenKeys = make(map[string]bool)
+		}
+	}
+	if v.Kind() == reflect.Struct && (d.disallowDups || fields.checkMissing) {
+		seenFields = make([]bool, len(fields.list))
+	}
+
 	for {
 
 		desc, err := d.decoder.Next()
@@ -249,8 +458,18 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
+		var f *field
+		var keyErr error
+		remain := false
 
 		if v.Kind() == reflect.Map {
+			if seenKeys != nil {
+				if seenKeys[key] {
+					keyErr = ErrDuplicateKey
+				}
+				seenKeys[key] = true
+			}
+
 			elemType := t.Elem()
 			if !mapElem.IsValid() {
 				mapElem = reflect.New(elemType).Elem()
@@ -259,21 +478,30 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 			}
 			subv = mapElem
 		} else {
-			var f *field
-			if i, ok := fields.nameIndex[key]; ok {
-				// Found an exact name match.
-				f = &fields.list[i]
-			} else {
-				// Fall back to the expensive case-insensitive
-				// linear search.
-				for i := range fields.list {
-					ff := &fields.list[i]
-					if ff.equalFold(ff.nameBytes, []byte(key)) {
-						f = ff
-						break
+			var i int
+			f, i = fields.byName(key, d.caseSensitive)
+			if f != nil && seenFields != nil {
+				if seenFields[i] && d.disallowDups {
+					keyErr = ErrDuplicateKey
+					f = nil
+				}
+				seenFields[i] = true
+			}
+			if f == nil && keyErr == nil {
+				switch {
+				case fields.remain != nil:
+					remain = true
+					if seenKeys != nil {
+						if seenKeys[key] {
+							keyErr = ErrDuplicateKey
+						}
+						seenKeys[key] = true
 					}
+				default:
+					keyErr = ErrUnknownField
 				}
 			}
+
 			if f != nil {
 				subv = v
 				for _, i := range f.index {
@@ -302,8 +530,6 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 				}
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
-			} else {
-				d.saveError(fmt.Errorf("ltv: unknown field %q", key))
 			}
 		}
 
@@ -313,7 +539,25 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 			return d.addErrorContext(err)
 		}
 
-		if err := d.value(desc, subv); err != nil {
+		if keyErr != nil {
+			d.saveError(&FieldError{Err: keyErr, Key: key, GoType: t})
+			d.skip(desc)
+			continue
+		}
+
+		if remain {
+			if err := d.remainValue(desc, v, fields.remain, key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if f != nil && f.columnar && desc.TypeCode == Struct && subv.IsValid() {
+			err = d.columns(desc, subv)
+		} else {
+			err = d.value(desc, subv)
+		}
+		if err != nil {
 			return err
 		}
 
@@ -365,13 +609,43 @@ func (d *decodeState) structure(desc LtvDesc, v reflect.Value) error {
 		}
 	}
 
+	if fields.checkMissing {
+		d.missingFields(&fields, seenFields, v)
+	}
+
 	return nil
 }
 
+// Find the field for a struct key and its index: an exact match or,
+// unless caseSensitive is set, the first case-insensitive match.
+func (fs *structFields) byName(key string, caseSensitive bool) (*field, int) {
+	if i, ok := fs.nameIndex[key]; ok {
+		return &fs.list[i], i
+	}
+
+	if !caseSensitive {
+		// Fall back to the expensive case-insensitive
+		// linear search.
+		for i := range fs.list {
+			ff := &fs.list[i]
+			if ff.equalFold(ff.nameBytes, []byte(key)) {
+				return ff, i
+			}
+		}
+	}
+
+	return nil, -1
+}
+
 func (d *decodeState) list(desc LtvDesc, v reflect.Value) error {
 
 	// Check for unmarshaler.
-	u, ut, pv := indirect(v, false)
+	uf, u, ut, pv := indirect(v, false)
+
+	// Use UnmarshalLTVFrom
+	if uf != nil {
+		return uf.UnmarshalLTVFrom(&d.decoder, desc)
+	}
 
 	// Use UnmarshalLTV
 	if u != nil {
@@ -394,7 +668,7 @@ func (d *decodeState) list(desc LtvDesc, v reflect.Value) error {
 	case reflect.Interface:
 		if v.NumMethod() == 0 {
 			// Decoding into nil interface? Switch to non-reflect code.
-			ai, err := d.decoder.readList()
+			ai, err := d.interfaceList()
 			if err != nil {
 				return err
 			}
@@ -475,7 +749,12 @@ func (d *decodeState) list(desc LtvDesc, v reflect.Value) error {
 
 func (d *decodeState) storeValue(desc LtvDesc, v reflect.Value) error {
 
-	u, ut, pv := indirect(v, desc.TypeCode == Nil)
+	uf, u, ut, pv := indirect(v, desc.TypeCode == Nil)
+
+	// UnmarshalLTVFrom
+	if uf != nil {
+		return uf.UnmarshalLTVFrom(&d.decoder, desc)
+	}
 
 	// UnmarshalLTV
 	if u != nil {
@@ -579,37 +858,15 @@ func (d *decodeState) storeValue(desc LtvDesc, v reflect.Value) error {
 		}
 	case F32:
 		if desc.SizeCode == SizeSingle {
-			switch v.Kind() {
-			default:
-				d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
-			case reflect.Float32, reflect.Float64:
-				v.SetFloat(float64(value.(float32)))
-			case reflect.Interface:
-				if v.NumMethod() == 0 {
-					v.Set(reflect.ValueOf(value))
-				} else {
-					d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
-				}
-			}
+			d.setSingleFloat(desc, v, float64(value.(float32)))
 		} else {
-			d.setElement(desc, v, value, reflect.Int64)
+			d.setElement(desc, v, value, reflect.Float32)
 		}
 	case F64:
 		if desc.SizeCode == SizeSingle {
-			switch v.Kind() {
-			default:
-				d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
-			case reflect.Float32, reflect.Float64:
-				v.SetFloat(value.(float64))
-			case reflect.Interface:
-				if v.NumMethod() == 0 {
-					v.Set(reflect.ValueOf(value))
-				} else {
-					d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
-				}
-			}
+			d.setSingleFloat(desc, v, value.(float64))
 		} else {
-			d.setElement(desc, v, value, reflect.Int64)
+			d.setElement(desc, v, value, reflect.Float64)
 		}
 	}
 
@@ -656,14 +913,14 @@ func (d *decodeState) setSingleInt(desc LtvDesc, v reflect.Value, value int64) {
 	default:
 		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if checkSignedOverflow(v, value) {
+		if checkSignedOverflow(v, value) || !d.numericAllowed(numericTypes[desc.TypeCode], v.Type()) {
 			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 			return
 		}
 		v.SetUint(uint64(value))
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if checkSignedOverflow(v, value) {
+		if checkSignedOverflow(v, value) || !d.numericAllowed(numericTypes[desc.TypeCode], v.Type()) {
 			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 			return
 		}
@@ -688,14 +945,14 @@ func (d *decodeState) setSingleUInt(desc LtvDesc, v reflect.Value, value uint64)
 	default:
 		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if checkUnsignedOverflow(v, value) {
+		if checkUnsignedOverflow(v, value) || !d.numericAllowed(numericTypes[desc.TypeCode], v.Type()) {
 			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 			return
 		}
 		v.SetUint(value)
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if checkUnsignedOverflow(v, value) {
+		if checkUnsignedOverflow(v, value) || !d.numericAllowed(numericTypes[desc.TypeCode], v.Type()) {
 			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 			return
 		}
@@ -715,6 +972,36 @@ func (d *decodeState) setSingleUInt(desc LtvDesc, v reflect.Value, value uint64)
 	}
 }
 
+func (d *decodeState) setSingleFloat(desc LtvDesc, v reflect.Value, value float64) {
+	switch v.Kind() {
+	default:
+		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+	case reflect.Float32, reflect.Float64:
+		if !d.numericAllowed(numericTypes[desc.TypeCode], v.Type()) {
+			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+			return
+		}
+		v.SetFloat(value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !d.integralFloats || !floatToInt(v, value) {
+			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		}
+
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			if desc.TypeCode == F32 {
+				v.Set(reflect.ValueOf(float32(value)))
+			} else {
+				v.Set(reflect.ValueOf(value))
+			}
+		} else {
+			d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		}
+	}
+}
+
 // A slow, messy, allocating process of shoehorning a integer vector
 // into a Go integer vector of another type.
 // This could probably be optimized quite a bit. The best way: don't do it.
@@ -786,6 +1073,75 @@ func floatVectorConv(dst reflect.Value, src any) any {
 	}
 }
 
+// Convert an interleaved [real, imaginary, ...] float vector to a
+// complex number or a slice of complex numbers of the destination type.
+func complexVectorConv(dst reflect.Value, src any) any {
+	srcSlice := reflect.ValueOf(src)
+	switch srcSlice.Type().Elem().Kind() {
+	case reflect.Float32, reflect.Float64:
+	default:
+		return nil
+	}
+
+	if dst.Kind() != reflect.Slice {
+		if srcSlice.Len() != 2 {
+			return nil
+		}
+		c := reflect.New(dst.Type()).Elem()
+		c.SetComplex(complex(srcSlice.Index(0).Float(), srcSlice.Index(1).Float()))
+		return c.Interface()
+	}
+
+	if srcSlice.Len()%2 != 0 {
+		return nil
+	}
+
+	n := srcSlice.Len() / 2
+	dstSlice := reflect.MakeSlice(dst.Type(), n, n)
+	for i := 0; i < n; i++ {
+		dstSlice.Index(i).SetComplex(complex(srcSlice.Index(2*i).Float(), srcSlice.Index(2*i+1).Float()))
+	}
+	return dstSlice.Interface()
+}
+
+// Convert a vector to a slice of interfaces, with integers stored as
+// native ints where they fit, as when decoding a list.
+func anyVectorConv(dst reflect.Value, src any) any {
+	srcSlice := reflect.ValueOf(src)
+	dstSlice := reflect.MakeSlice(dst.Type(), srcSlice.Len(), srcSlice.Len())
+
+	for i := 0; i < srcSlice.Len(); i++ {
+		elem := srcSlice.Index(i)
+		switch elem.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := elem.Int(); n >= math.MinInt && n <= math.MaxInt {
+				elem = reflect.ValueOf(int(n))
+			}
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n := elem.Uint(); n <= math.MaxInt {
+				elem = reflect.ValueOf(int(n))
+			}
+		}
+		dstSlice.Index(i).Set(elem)
+	}
+
+	return dstSlice.Interface()
+}
+
+// Convert a vector to a slice of the same kind of element,
+// with a different element type.
+func namedVectorConv(dst reflect.Value, src any) any {
+	elemType := dst.Type().Elem()
+	srcSlice := reflect.ValueOf(src)
+	dstSlice := reflect.MakeSlice(dst.Type(), srcSlice.Len(), srcSlice.Len())
+
+	for i := 0; i < srcSlice.Len(); i++ {
+		dstSlice.Index(i).Set(srcSlice.Index(i).Convert(elemType))
+	}
+
+	return dstSlice.Interface()
+}
+
 func vectorConv(dst reflect.Value, src any) any {
 
 	srcType := reflect.TypeOf(src)
@@ -794,6 +1150,8 @@ func vectorConv(dst reflect.Value, src any) any {
 	switch dst.Kind() {
 	default:
 		return nil
+	case reflect.Complex64, reflect.Complex128:
+		return complexVectorConv(dst, src)
 	case reflect.Slice:
 
 		// Make sure the source is a vector
@@ -804,21 +1162,43 @@ func vectorConv(dst reflect.Value, src any) any {
 		dstKind := dst.Type().Elem().Kind()
 		srcKind := srcType.Elem().Kind()
 
-		// If they're the same kind, just use the original source
-		if dstKind == srcKind {
+		// Complex numbers from interleaved floats
+		if dstKind == reflect.Complex64 || dstKind == reflect.Complex128 {
+			return complexVectorConv(dst, src)
+		}
+
+		// A list of interfaces, e.g. from a compact []any
+		if dstKind == reflect.Interface {
+			if dst.Type().Elem().NumMethod() != 0 {
+				return nil
+			}
+			return anyVectorConv(dst, src)
+		}
+
+		// If they're the same type, just use the original source
+		if dst.Type().Elem() == srcType.Elem() {
 			return src
 		}
 
+		// A named element type of the same kind, e.g. type Celsius float32
+		if dstKind == srcKind {
+			return namedVectorConv(dst, src)
+		}
+
 		// Potentially convert integer slices
 		if (dstKind >= reflect.Int && dstKind <= reflect.Uint64) &&
-			(srcKind >= reflect.Int || srcKind <= reflect.Uint64) {
+			(srcKind >= reflect.Int && srcKind <= reflect.Uint64) {
 			return intVectorConv(dst, src)
 		}
 
 		// Convert floating point slices
 		if (dstKind == reflect.Float32 || dstKind == reflect.Float64) &&
 			(srcKind == reflect.Float32 || srcKind == reflect.Float64) {
-			return floatVectorConv(dst, src)
+			vec := floatVectorConv(dst, src)
+			if reflect.TypeOf(vec).Elem() != dst.Type().Elem() {
+				vec = namedVectorConv(dst, vec)
+			}
+			return vec
 		}
 
 		// Incompatible
@@ -851,9 +1231,11 @@ func (d *decodeState) setElement(desc LtvDesc, v reflect.Value, value any, kind
 				d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
 			}
 		}
+	} else if v.Kind() == reflect.Array {
+		d.setArray(desc, v, value)
 	} else {
 		// Vector
-		vec := vectorConv(v, value)
+		vec := d.numericVectorConv(v, value)
 		if vec != nil {
 			v.Set(reflect.ValueOf(vec))
 		} else {
@@ -877,12 +1259,40 @@ func (d *decodeState) setElement(desc LtvDesc, v reflect.Value, value any, kind
 	}
 }
 
+// Copy a vector into a Go array.
+func (d *decodeState) setArray(desc LtvDesc, v reflect.Value, value any) {
+	elemType := v.Type().Elem()
+
+	vec := d.numericVectorConv(reflect.New(reflect.SliceOf(elemType)).Elem(), value)
+	if vec == nil {
+		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		return
+	}
+
+	sv := reflect.ValueOf(vec)
+	if sv.Len() != v.Len() && !d.truncateArrays {
+		d.saveError(&UnmarshalTypeError{Desc: desc, GoType: v.Type()})
+		return
+	}
+
+	i := 0
+	for ; i < v.Len() && i < sv.Len(); i++ {
+		v.Index(i).Set(sv.Index(i).Convert(elemType))
+	}
+
+	// Zero the rest
+	z := reflect.Zero(elemType)
+	for ; i < v.Len(); i++ {
+		v.Index(i).Set(z)
+	}
+}
+
 // indirect walks down v allocating pointers as needed,
 // until it gets to a non-pointer.
-// If it encounters an Unmarshaler, indirect stops and returns that.
+// If it encounters an UnmarshalerFrom or Unmarshaler, indirect stops and returns that.
 // If decodingNull is true, indirect stops at the first settable pointer so it
 // can be set to nil.
-func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+func indirect(v reflect.Value, decodingNull bool) (UnmarshalerFrom, Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
 	// Issue #24153 indicates that it is generally not a guaranteed property
 	// that you may round-trip a reflect.Value by calling Value.Addr().Elem()
 	// and expect the value to still be settable for values derived from
@@ -935,12 +1345,15 @@ func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, encoding.TextUnm
 			v.Set(reflect.New(v.Type().Elem()))
 		}
 		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(UnmarshalerFrom); ok {
+				return u, nil, nil, reflect.Value{}
+			}
 			if u, ok := v.Interface().(Unmarshaler); ok {
-				return u, nil, reflect.Value{}
+				return nil, u, nil, reflect.Value{}
 			}
 			if !decodingNull {
 				if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
-					return nil, u, reflect.Value{}
+					return nil, nil, u, reflect.Value{}
 				}
 			}
 		}
@@ -952,5 +1365,5 @@ func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, encoding.TextUnm
 			v = v.Elem()
 		}
 	}
-	return nil, nil, v
+	return nil, nil, nil, v
 }
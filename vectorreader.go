@@ -0,0 +1,182 @@
+package ltvgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A VectorReader reads the elements of a vector from a StreamDecoder in
+// chunks, into slices provided by the caller, so vectors of any length can
+// be read in constant memory and without regard to MaxValueLength:
+//
+//	desc, _ := d.Next()
+//	r, err := ltvgo.NewVectorReader(d, desc)
+//	...
+//	buf := make([]float32, 4096)
+//	for {
+//		n, err := r.ReadF32(buf)
+//		process(buf[:n])
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+//
+// Each Read method returns the number of elements read, and io.EOF once
+// the vector has been read completely. Reading with the method for another
+// type is an error. The decoder is positioned after the vector once it has
+// been read, or after Skip.
+type VectorReader struct {
+	d         *StreamDecoder
+	typ       TypeCode
+	remaining uint64 // Bytes left to read
+	scratch   [512]byte
+}
+
+// NewVectorReader returns a reader for the vector described by desc,
+// which must be the last descriptor returned by d.Next.
+func NewVectorReader(d *StreamDecoder, desc LtvElementDesc) (*VectorReader, error) {
+	if desc.SizeCode == SizeSingle || desc.TypeCode < String {
+		return nil, fmt.Errorf("ltv: cannot read %s element as a vector", desc.TypeCode)
+	}
+
+	return &VectorReader{
+		d:         d,
+		typ:       desc.TypeCode,
+		remaining: desc.Length,
+	}, nil
+}
+
+// Type returns the element type of the vector.
+func (r *VectorReader) Type() TypeCode {
+	return r.typ
+}
+
+// Remaining returns the number of elements left to read.
+func (r *VectorReader) Remaining() int {
+	return int(r.remaining / uint64(r.typ.Size()))
+}
+
+// Skip the unread remainder of the vector.
+func (r *VectorReader) Skip() error {
+	for r.remaining > 0 {
+		n := uint64(len(r.scratch))
+		if r.remaining < n {
+			n = r.remaining
+		}
+		if err := r.d.ReadFull(r.scratch[:n]); err != nil {
+			return unexpectedEOF(err)
+		}
+		r.remaining -= n
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Read up to len(dst) elements of the wanted type, converting each from
+// its little endian encoding.
+func readVector[T any](r *VectorReader, want TypeCode, dst []T, conv func([]byte) T) (int, error) {
+	if r.typ != want {
+		return 0, fmt.Errorf("ltv: cannot read %s vector as %s", r.typ, want)
+	}
+
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	size := want.Size()
+	perChunk := len(r.scratch) / size
+
+	n := 0
+	for n < len(dst) && r.remaining > 0 {
+		count := len(dst) - n
+		if count > perChunk {
+			count = perChunk
+		}
+		if left := int(r.remaining / uint64(size)); count > left {
+			count = left
+		}
+
+		b := r.scratch[:count*size]
+		if err := r.d.ReadFull(b); err != nil {
+			return n, unexpectedEOF(err)
+		}
+		r.remaining -= uint64(len(b))
+
+		for i := 0; i < count; i++ {
+			dst[n+i] = conv(b[i*size:])
+		}
+		n += count
+	}
+
+	return n, nil
+}
+
+// Read reads the bytes of a U8 or String vector, implementing io.Reader.
+func (r *VectorReader) Read(p []byte) (int, error) {
+	if r.typ != U8 && r.typ != String {
+		return 0, fmt.Errorf("ltv: cannot read %s vector as bytes", r.typ)
+	}
+
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if uint64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	if err := r.d.ReadFull(p); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	r.remaining -= uint64(len(p))
+
+	return len(p), nil
+}
+
+func (r *VectorReader) ReadBool(dst []bool) (int, error) {
+	return readVector(r, Bool, dst, func(b []byte) bool { return b[0] != 0 })
+}
+
+func (r *VectorReader) ReadU16(dst []uint16) (int, error) {
+	return readVector(r, U16, dst, binary.LittleEndian.Uint16)
+}
+
+func (r *VectorReader) ReadU32(dst []uint32) (int, error) {
+	return readVector(r, U32, dst, binary.LittleEndian.Uint32)
+}
+
+func (r *VectorReader) ReadU64(dst []uint64) (int, error) {
+	return readVector(r, U64, dst, binary.LittleEndian.Uint64)
+}
+
+func (r *VectorReader) ReadI8(dst []int8) (int, error) {
+	return readVector(r, I8, dst, func(b []byte) int8 { return int8(b[0]) })
+}
+
+func (r *VectorReader) ReadI16(dst []int16) (int, error) {
+	return readVector(r, I16, dst, func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) })
+}
+
+func (r *VectorReader) ReadI32(dst []int32) (int, error) {
+	return readVector(r, I32, dst, func(b []byte) int32 { return int32(binary.LittleEndian.Uint32(b)) })
+}
+
+func (r *VectorReader) ReadI64(dst []int64) (int, error) {
+	return readVector(r, I64, dst, func(b []byte) int64 { return int64(binary.LittleEndian.Uint64(b)) })
+}
+
+func (r *VectorReader) ReadF32(dst []float32) (int, error) {
+	return readVector(r, F32, dst, func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) })
+}
+
+func (r *VectorReader) ReadF64(dst []float64) (int, error) {
+	return readVector(r, F64, dst, func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) })
+}
@@ -0,0 +1,391 @@
+package ltvgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"unicode/utf8"
+)
+
+// Incremental vector writing.
+//
+// BeginVector starts a vector of unknown length, the Append methods for
+// its type add elements to it as they arrive, and EndVector completes it:
+//
+//	e.BeginVector(ltvgo.F32)
+//	for sample := range samples {
+//		e.AppendF32(sample)
+//	}
+//	e.EndVector()
+//
+// The Encoder reserves a 64-bit length, aligns the vector data for it, and
+// fills the length in at EndVector. The StreamEncoder can't go back, so it
+// spools the elements in memory, or in a temporary file beyond
+// SpoolMemLimit bytes, and writes the whole vector at EndVector.
+//
+// Nothing else may be written between BeginVector and EndVector. Appending
+// elements of another type, or without a BeginVector, panics.
+//
+// A String vector may split a rune across AppendString calls, but must be
+// valid UTF-8 as a whole. Otherwise EndVector returns an error, and the
+// vector is dropped from an Encoder.
+
+// Little endian element writers
+func putBool(b []byte, v bool) {
+	if v {
+		b[0] = 1
+	} else {
+		b[0] = 0
+	}
+}
+
+func putI8(b []byte, v int8)     { b[0] = uint8(v) }
+func putI16(b []byte, v int16)   { binary.LittleEndian.PutUint16(b, uint16(v)) }
+func putI32(b []byte, v int32)   { binary.LittleEndian.PutUint32(b, uint32(v)) }
+func putI64(b []byte, v int64)   { binary.LittleEndian.PutUint64(b, uint64(v)) }
+func putF32(b []byte, v float32) { binary.LittleEndian.PutUint32(b, math.Float32bits(v)) }
+func putF64(b []byte, v float64) { binary.LittleEndian.PutUint64(b, math.Float64bits(v)) }
+
+func putElems[T any](dst []byte, v []T, size int, put func([]byte, T)) {
+	if dst == nil {
+		return // Dropped by a fixed Encoder
+	}
+	for i, x := range v {
+		put(dst[i*size:], x)
+	}
+}
+
+// Validates the UTF-8 of a String vector across AppendString calls,
+// which may split a rune.
+type stringCheck struct {
+	tail [utf8.UTFMax]byte
+	n    int // Bytes of an incomplete rune in tail
+	bad  bool
+}
+
+func (c *stringCheck) write(s string) {
+	if c.bad {
+		return
+	}
+
+	// Complete a rune split by the previous call
+	for c.n > 0 && len(s) > 0 {
+		c.tail[c.n] = s[0]
+		c.n++
+		s = s[1:]
+		if utf8.FullRune(c.tail[:c.n]) {
+			if r, size := utf8.DecodeRune(c.tail[:c.n]); r == utf8.RuneError && size == 1 {
+				c.bad = true
+				return
+			}
+			c.n = 0
+		}
+	}
+
+	// Hold back an incomplete rune at the end
+	i := len(s)
+	for j := len(s) - 1; j >= 0 && j >= len(s)-(utf8.UTFMax-1); j-- {
+		if utf8.RuneStart(s[j]) {
+			if !utf8.FullRuneInString(s[j:]) {
+				i = j
+			}
+			break
+		}
+	}
+	c.n += copy(c.tail[c.n:], s[i:])
+
+	if !utf8.ValidString(s[:i]) {
+		c.bad = true
+	}
+}
+
+func (c *stringCheck) valid() bool {
+	return !c.bad && c.n == 0
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Encoder
+
+// An open vector of an Encoder.
+type openVector struct {
+	open  bool
+	typ   TypeCode
+	begin int // Start of the vector, padding included
+	start int // Start of the vector data in the buffer
+	str   stringCheck
+}
+
+// BeginVector starts a vector of the given type, of unknown length.
+func (e *Encoder) BeginVector(t TypeCode) {
+	if e.vec.open {
+		panic("ltv: BeginVector called with a vector already open")
+	}
+	if t < String {
+		panic("ltv: BeginVector requires a vector type")
+	}
+
+	// Align the data for a 64-bit length
+	begin := e.Len()
+	typeSize := typeSizes[t]
+	if delta := (e.Len() + 1 + 8) & (typeSize - 1); delta != 0 {
+		for i := 0; i < typeSize-delta; i++ {
+			e.WriteNop()
+		}
+	}
+
+	e.WriteTag(t, Size8)
+	e.RawWriteUint64(0)
+	e.vec = openVector{open: true, typ: t, begin: begin, start: e.Len()}
+}
+
+// EndVector completes the vector started by BeginVector.
+func (e *Encoder) EndVector() error {
+	if !e.vec.open {
+		panic("ltv: EndVector called without BeginVector")
+	}
+	if e.vec.typ == String && !e.vec.str.valid() {
+		e.Truncate(e.vec.begin)
+		e.vec = openVector{}
+		return errBadUtf8
+	}
+	if e.over == 0 {
+		binary.LittleEndian.PutUint64(e.buf[e.vec.start-8:], uint64(len(e.buf)-e.vec.start))
+	}
+	e.vec = openVector{}
+	return nil
+}
+
+// Reserve space for count elements of an open vector of type t.
+func (e *Encoder) appendVector(t TypeCode, count int) []byte {
+	if !e.vec.open || e.vec.typ != t {
+		panic("ltv: Append" + t.String() + " called without a " + t.String() + " vector open")
+	}
+	idx := e.grow(count * typeSizes[t])
+	if idx < 0 {
+		return nil
+	}
+	return e.buf[idx:]
+}
+
+func (e *Encoder) AppendString(s string) {
+	b := e.appendVector(String, len(s))
+	e.vec.str.write(s)
+	copy(b, s)
+}
+
+func (e *Encoder) AppendBool(v ...bool) {
+	putElems(e.appendVector(Bool, len(v)), v, 1, putBool)
+}
+
+func (e *Encoder) AppendU8(v ...uint8) {
+	copy(e.appendVector(U8, len(v)), v)
+}
+
+func (e *Encoder) AppendU16(v ...uint16) {
+	putElems(e.appendVector(U16, len(v)), v, 2, binary.LittleEndian.PutUint16)
+}
+
+func (e *Encoder) AppendU32(v ...uint32) {
+	putElems(e.appendVector(U32, len(v)), v, 4, binary.LittleEndian.PutUint32)
+}
+
+func (e *Encoder) AppendU64(v ...uint64) {
+	putElems(e.appendVector(U64, len(v)), v, 8, binary.LittleEndian.PutUint64)
+}
+
+func (e *Encoder) AppendI8(v ...int8) {
+	putElems(e.appendVector(I8, len(v)), v, 1, putI8)
+}
+
+func (e *Encoder) AppendI16(v ...int16) {
+	putElems(e.appendVector(I16, len(v)), v, 2, putI16)
+}
+
+func (e *Encoder) AppendI32(v ...int32) {
+	putElems(e.appendVector(I32, len(v)), v, 4, putI32)
+}
+
+func (e *Encoder) AppendI64(v ...int64) {
+	putElems(e.appendVector(I64, len(v)), v, 8, putI64)
+}
+
+func (e *Encoder) AppendF32(v ...float32) {
+	putElems(e.appendVector(F32, len(v)), v, 4, putF32)
+}
+
+func (e *Encoder) AppendF64(v ...float64) {
+	putElems(e.appendVector(F64, len(v)), v, 8, putF64)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// StreamEncoder
+
+// A vector being spooled by a StreamEncoder.
+type spool struct {
+	typ     TypeCode
+	size    int // Bytes spooled
+	mem     bytes.Buffer
+	file    *os.File
+	scratch []byte
+	str     stringCheck
+}
+
+// Spool the encoded elements, moving to a temporary file beyond the limit.
+func (e *StreamEncoder) spoolWrite(b []byte) {
+	s := e.spool
+	s.size += len(b)
+	if e.Werr != nil {
+		return
+	}
+
+	if s.file == nil && e.SpoolMemLimit > 0 && s.mem.Len()+len(b) > e.SpoolMemLimit {
+		s.file, e.Werr = os.CreateTemp(e.SpoolDir, "ltv-spool-*")
+		if e.Werr != nil {
+			return
+		}
+		_, e.Werr = s.mem.WriteTo(s.file)
+	}
+
+	if s.file != nil {
+		if e.Werr == nil {
+			_, e.Werr = s.file.Write(b)
+		}
+	} else {
+		s.mem.Write(b)
+	}
+}
+
+// BeginVector starts a vector of the given type, of unknown length.
+func (e *StreamEncoder) BeginVector(t TypeCode) {
+	if e.spool != nil {
+		panic("ltv: BeginVector called with a vector already open")
+	}
+	if t < String {
+		panic("ltv: BeginVector requires a vector type")
+	}
+	e.spool = &spool{typ: t}
+}
+
+// EndVector writes the vector started by BeginVector to the stream.
+func (e *StreamEncoder) EndVector() error {
+	s := e.spool
+	if s == nil {
+		panic("ltv: EndVector called without BeginVector")
+	}
+	e.spool = nil
+
+	if s.file != nil {
+		defer os.Remove(s.file.Name())
+		defer s.file.Close()
+	}
+
+	if s.typ == String && !s.str.valid() && e.Werr == nil {
+		e.Werr = errBadUtf8
+	}
+
+	e.WriteVectorPrefix(s.typ, s.size/typeSizes[s.typ])
+	if e.Werr != nil {
+		return e.Werr
+	}
+
+	if s.file == nil {
+		e.RawWrite(s.mem.Bytes())
+		return e.Werr
+	}
+
+	if _, e.Werr = s.file.Seek(0, io.SeekStart); e.Werr != nil {
+		return e.Werr
+	}
+	var n int64
+	n, e.Werr = io.Copy(e.w, s.file)
+	e.offset += int(n)
+	return e.Werr
+}
+
+// Encode count elements of an open vector of type t into a scratch buffer,
+// to be passed to spoolWrite.
+func (e *StreamEncoder) appendVector(t TypeCode, count int) []byte {
+	if e.spool == nil || e.spool.typ != t {
+		panic("ltv: Append" + t.String() + " called without a " + t.String() + " vector open")
+	}
+	n := count * typeSizes[t]
+	if cap(e.spool.scratch) < n {
+		e.spool.scratch = make([]byte, n)
+	}
+	return e.spool.scratch[:n]
+}
+
+func (e *StreamEncoder) AppendString(s string) {
+	b := e.appendVector(String, len(s))
+	e.spool.str.write(s)
+	copy(b, s)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendBool(v ...bool) {
+	b := e.appendVector(Bool, len(v))
+	putElems(b, v, 1, putBool)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendU8(v ...uint8) {
+	b := e.appendVector(U8, len(v))
+	copy(b, v)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendU16(v ...uint16) {
+	b := e.appendVector(U16, len(v))
+	putElems(b, v, 2, binary.LittleEndian.PutUint16)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendU32(v ...uint32) {
+	b := e.appendVector(U32, len(v))
+	putElems(b, v, 4, binary.LittleEndian.PutUint32)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendU64(v ...uint64) {
+	b := e.appendVector(U64, len(v))
+	putElems(b, v, 8, binary.LittleEndian.PutUint64)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendI8(v ...int8) {
+	b := e.appendVector(I8, len(v))
+	putElems(b, v, 1, putI8)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendI16(v ...int16) {
+	b := e.appendVector(I16, len(v))
+	putElems(b, v, 2, putI16)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendI32(v ...int32) {
+	b := e.appendVector(I32, len(v))
+	putElems(b, v, 4, putI32)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendI64(v ...int64) {
+	b := e.appendVector(I64, len(v))
+	putElems(b, v, 8, putI64)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendF32(v ...float32) {
+	b := e.appendVector(F32, len(v))
+	putElems(b, v, 4, putF32)
+	e.spoolWrite(b)
+}
+
+func (e *StreamEncoder) AppendF64(v ...float64) {
+	b := e.appendVector(F64, len(v))
+	putElems(b, v, 8, putF64)
+	e.spoolWrite(b)
+}
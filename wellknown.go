@@ -0,0 +1,231 @@
+package ltvgo
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// Native encodings for standard library types which would otherwise
+// be written as strings or bare integers:
+//
+//	time.Time      I64 Unix nanoseconds, or {"sec": I64, "nsec": I32}
+//	               for times outside the range of I64 nanoseconds
+//	time.Duration  I64 nanoseconds
+//	*big.Int       {"sign": I8, "mag": U8 vector}, the big endian magnitude
+//	netip.Addr     4 or 16 byte U8 vector (empty for the zero Addr)
+//	net.IP         4 or 16 byte U8 vector
+//
+// The encodings are used by Marshal and Unmarshal when the WellKnownTypes
+// field of MarshalOptions or UnmarshalOptions is set. Time zones and IPv6
+// zones are not preserved; times decode in the local time zone.
+// Decoder.ReadValue is unaffected and returns the underlying values.
+var wellKnownCodecs Codecs
+
+// Struct keys of the well-known type encodings
+const (
+	timeSecKey  = "sec"
+	timeNsecKey = "nsec"
+	bigSignKey  = "sign"
+	bigMagKey   = "mag"
+)
+
+var (
+	errBadTime     = errors.New("ltv: invalid time encoding")
+	errBadDuration = errors.New("ltv: invalid duration encoding")
+	errBadBig      = errors.New("ltv: invalid big.Int encoding")
+	errBadAddr     = errors.New("ltv: invalid IP address encoding")
+)
+
+func init() {
+	AddCodec(&wellKnownCodecs, encodeTime, decodeTime)
+	AddCodec(&wellKnownCodecs, encodeDuration, decodeDuration)
+	AddCodec(&wellKnownCodecs, encodeBigInt, decodeBigInt)
+	AddCodec(&wellKnownCodecs, encodeAddr, decodeAddr)
+	AddCodec(&wellKnownCodecs, encodeIP, decodeIP)
+}
+
+// Convert a generic single integer value to an int64.
+func toInt64(v any) (int64, bool) {
+	switch i := v.(type) {
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint8:
+		return int64(i), true
+	case uint16:
+		return int64(i), true
+	case uint32:
+		return int64(i), true
+	case uint64:
+		if i > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(i), true
+	}
+	return 0, false
+}
+
+func encodeTime(e LtvEncoder, t time.Time) error {
+	sec := t.Unix()
+	if sec > math.MinInt64/int64(time.Second) && sec < math.MaxInt64/int64(time.Second) {
+		e.WriteI64(t.UnixNano())
+		return nil
+	}
+
+	e.WriteStructStart()
+	e.WriteString(timeSecKey)
+	e.WriteI64(sec)
+	e.WriteString(timeNsecKey)
+	e.WriteI32(int32(t.Nanosecond()))
+	e.WriteStructEnd()
+	return nil
+}
+
+func decodeTime(d *Decoder, desc LtvDesc) (time.Time, error) {
+	v, err := d.ReadValue(desc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if ns, ok := toInt64(v); ok && desc.SizeCode == SizeSingle {
+		return time.Unix(0, ns), nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return time.Time{}, errBadTime
+	}
+
+	sec, ok1 := toInt64(m[timeSecKey])
+	nsec, ok2 := toInt64(m[timeNsecKey])
+	if !ok1 || !ok2 {
+		return time.Time{}, errBadTime
+	}
+
+	return time.Unix(sec, nsec), nil
+}
+
+func encodeDuration(e LtvEncoder, d time.Duration) error {
+	e.WriteI64(int64(d))
+	return nil
+}
+
+func decodeDuration(d *Decoder, desc LtvDesc) (time.Duration, error) {
+	v, err := d.ReadValue(desc)
+	if err != nil {
+		return 0, err
+	}
+
+	ns, ok := toInt64(v)
+	if !ok || desc.SizeCode != SizeSingle {
+		return 0, errBadDuration
+	}
+
+	return time.Duration(ns), nil
+}
+
+func encodeBigInt(e LtvEncoder, b *big.Int) error {
+	if b == nil {
+		e.WriteNil()
+		return nil
+	}
+	e.WriteStructStart()
+	e.WriteString(bigSignKey)
+	e.WriteI8(int8(b.Sign()))
+	e.WriteString(bigMagKey)
+	e.WriteU8Vec(b.Bytes())
+	e.WriteStructEnd()
+	return nil
+}
+
+func decodeBigInt(d *Decoder, desc LtvDesc) (*big.Int, error) {
+	v, err := d.ReadValue(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, errBadBig
+	}
+
+	sign, ok1 := toInt64(m[bigSignKey])
+	mag, ok2 := m[bigMagKey].([]byte)
+	if !ok1 || !ok2 || sign < -1 || sign > 1 {
+		return nil, errBadBig
+	}
+
+	b := new(big.Int).SetBytes(mag)
+	if sign < 0 {
+		b.Neg(b)
+	}
+
+	return b, nil
+}
+
+func encodeAddr(e LtvEncoder, a netip.Addr) error {
+	if !a.IsValid() {
+		e.WriteU8Vec([]byte{})
+		return nil
+	}
+	e.WriteU8Vec(a.AsSlice())
+	return nil
+}
+
+func decodeAddr(d *Decoder, desc LtvDesc) (netip.Addr, error) {
+	v, err := d.ReadValue(desc)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	b, ok := v.([]byte)
+	if !ok {
+		return netip.Addr{}, errBadAddr
+	}
+
+	if len(b) == 0 {
+		return netip.Addr{}, nil
+	}
+
+	a, ok := netip.AddrFromSlice(b)
+	if !ok {
+		return netip.Addr{}, errBadAddr
+	}
+
+	return a, nil
+}
+
+func encodeIP(e LtvEncoder, ip net.IP) error {
+	if ip == nil {
+		e.WriteNil()
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	e.WriteU8Vec(ip)
+	return nil
+}
+
+func decodeIP(d *Decoder, desc LtvDesc) (net.IP, error) {
+	v, err := d.ReadValue(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := v.([]byte)
+	if !ok || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, errBadAddr
+	}
+
+	return append(net.IP(nil), b...), nil
+}